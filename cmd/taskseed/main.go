@@ -9,10 +9,14 @@ import (
 )
 
 type CLI struct {
-	Verbose bool                    `name:"verbose" help:"Enable verbose (debug) logging." env:"TASKSEED_VERBOSE"`
-	Sync    commands.SyncCommand    `cmd:"" help:"Synchronize tasks with CalDAV." default:"1"`
-	Doctor  commands.DoctorCommand  `cmd:"" help:"Validate configuration and connectivity."`
-	Version commands.VersionCommand `cmd:"" help:"Show version information."`
+	Verbose     bool                        `name:"verbose" help:"Enable verbose (debug) logging." env:"TASKSEED_VERBOSE"`
+	Sync        commands.SyncCommand        `cmd:"" help:"Synchronize tasks with CalDAV." default:"1"`
+	Prune       commands.PruneCommand       `cmd:"" help:"Remove tasks of rules that no longer exist."`
+	MigrateRule commands.MigrateRuleCommand `cmd:"" help:"Move existing tasks from an old rule ID to a renamed rule."`
+	Calendars   commands.CalendarsCommand   `cmd:"" help:"List task lists available on the CalDAV server."`
+	Init        commands.InitCommand        `cmd:"" help:"Write a starter configuration for a discovered task list."`
+	Doctor      commands.DoctorCommand      `cmd:"" help:"Validate configuration and connectivity."`
+	Version     commands.VersionCommand     `cmd:"" help:"Show version information."`
 }
 
 func main() {
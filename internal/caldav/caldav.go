@@ -7,62 +7,109 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
 	"github.com/emersion/go-webdav"
 	"github.com/emersion/go-webdav/caldav"
-	"github.com/justinrixx/retryhttp"
 )
 
-// Client handles CalDAV operations.
+// Client handles CalDAV operations. A client created by NewClient is bound to a
+// server; WithCalendar derives clients for task lists on that server.
 type Client struct {
 	client       *caldav.Client
+	httpClient   webdav.HTTPClient
+	endpoint     *url.URL
 	calendarPath string
 }
 
 // Task represents an existing CalDAV VTODO.
 type Task struct {
-	UID        string
-	Summary    string
-	InstanceID string
-	RuleID     string
-	Occurrence string
-	Completed  bool
+	UID             string
+	Summary         string
+	InstanceID      string
+	IdentityVersion int
+	RuleID          string
+	Occurrence      string
+	Completed       bool
+	Cancelled       bool
+	CompletedAt     *time.Time
+	ContentHash     string
+	Path            string
+	ETag            string
+	data            *ical.Calendar
 }
 
 // NewTask represents a VTODO to create.
 type NewTask struct {
-	UID        string
-	Summary    string
-	Notes      string
-	Due        time.Time
-	DateOnly   bool
-	InstanceID string
-	RuleID     string
-	Occurrence string
-	Timezone   string
+	UID             string
+	Summary         string
+	Notes           string
+	Due             time.Time
+	DueForm         DueForm
+	InstanceID      string
+	IdentityVersion int
+	RuleID          string
+	Occurrence      string
+	Timezone        string
+	ContentHash     string
 }
 
+// DueForm selects how the due time of a task is written.
+type DueForm int
+
+const (
+	// DueFormZoned writes the local time qualified with Timezone as TZID.
+	DueFormZoned DueForm = iota
+	// DueFormUTC writes the due time in UTC.
+	DueFormUTC
+	// DueFormFloating writes the local time without timezone.
+	DueFormFloating
+	// DueFormDate writes the date only.
+	DueFormDate
+)
+
+// floatingLayout formats DATE-TIME values without UTC designator.
+const floatingLayout = "20060102T150405"
+
 const (
 	taskseedIDProp   = "X-TASKSEED-ID"
 	taskseedRuleProp = "X-TASKSEED-RULE"
 	taskseedOccProp  = "X-TASKSEED-OCC"
+	taskseedHashProp = "X-TASKSEED-HASH"
+	taskseedIDVProp  = "X-TASKSEED-IDV"
 )
 
-// NewClient creates an authenticated CalDAV client.
-func NewClient(endpoint, calendarURL, username, password string) (*Client, error) {
-	baseClient := &http.Client{
-		Transport: retryhttp.New(),
+// NewClient creates a CalDAV client for a server whose requests are sent
+// through transport, which is expected to authenticate them.
+func NewClient(endpoint string, transport http.RoundTripper) (*Client, error) {
+	httpClient := &http.Client{
+		Transport: transport,
 	}
-	httpClient := webdav.HTTPClientWithBasicAuth(baseClient, username, password)
 	calClient, err := caldav.NewClient(httpClient, endpoint)
 	if err != nil {
 		slog.Error("failed to create caldav client", "error", err)
 		return nil, fmt.Errorf("create caldav client: %w", err)
 	}
 
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		slog.Error("failed to parse endpoint url", "error", err)
+		return nil, fmt.Errorf("parse endpoint URL: %w", err)
+	}
+
+	return &Client{
+		client:     calClient,
+		httpClient: httpClient,
+		endpoint:   endpointURL,
+	}, nil
+}
+
+// WithCalendar returns a client for the given task list that shares the
+// server connection and credentials of c.
+func (c *Client) WithCalendar(calendarURL string) (*Client, error) {
 	path := calendarURL
 	if u, err := url.Parse(calendarURL); err == nil && u.Path != "" {
 		path = u.Path
@@ -71,10 +118,9 @@ func NewClient(endpoint, calendarURL, username, password string) (*Client, error
 		return nil, fmt.Errorf("parse calendar URL: %w", err)
 	}
 
-	return &Client{
-		client:       calClient,
-		calendarPath: path,
-	}, nil
+	calendar := *c
+	calendar.calendarPath = path
+	return &calendar, nil
 }
 
 // QueryTasks fetches VTODO tasks in the provided time range.
@@ -103,6 +149,81 @@ func (c *Client) QueryTasks(ctx context.Context, start, end time.Time) ([]Task,
 		return nil, fmt.Errorf("query caldav tasks: %w", err)
 	}
 
+	return objectsToTasks(objects, nil), nil
+}
+
+// QueryTaggedTasks fetches every VTODO carrying a taskseed instance ID and
+// applies the time range on the client. It serves servers whose time-range
+// filters on VTODOs are unreliable.
+func (c *Client) QueryTaggedTasks(ctx context.Context, start, end time.Time) ([]Task, error) {
+	objects, err := c.queryTagged(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return objectsToTasks(objects, func(comp *ical.Component) bool {
+		return comp.Props.Get(taskseedIDProp) != nil && inWindow(comp, start, end)
+	}), nil
+}
+
+// QueryAllTaggedTasks fetches every VTODO carrying a taskseed instance ID,
+// open or completed, regardless of its dates.
+func (c *Client) QueryAllTaggedTasks(ctx context.Context) ([]Task, error) {
+	objects, err := c.queryTagged(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return objectsToTasks(objects, func(comp *ical.Component) bool {
+		return comp.Props.Get(taskseedIDProp) != nil
+	}), nil
+}
+
+// QueryOpenTaggedTasks fetches every open VTODO carrying a taskseed instance
+// ID, regardless of its dates. Completed tasks are left out.
+func (c *Client) QueryOpenTaggedTasks(ctx context.Context) ([]Task, error) {
+	objects, err := c.queryTagged(ctx, caldav.PropFilter{Name: ical.PropCompleted, IsNotDefined: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return objectsToTasks(objects, func(comp *ical.Component) bool {
+		return comp.Props.Get(taskseedIDProp) != nil && comp.Props.Get(ical.PropCompleted) == nil
+	}), nil
+}
+
+// queryTagged fetches the calendar objects holding a VTODO with a taskseed
+// instance ID that also matches filters.
+func (c *Client) queryTagged(ctx context.Context, filters ...caldav.PropFilter) ([]caldav.CalendarObject, error) {
+	query := caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name: "VCALENDAR",
+			Comps: []caldav.CalendarCompRequest{{
+				Name:     "VTODO",
+				AllProps: true,
+			}},
+		},
+		CompFilter: caldav.CompFilter{
+			Name: "VCALENDAR",
+			Comps: []caldav.CompFilter{{
+				Name:  "VTODO",
+				Props: append([]caldav.PropFilter{{Name: taskseedIDProp}}, filters...),
+			}},
+		},
+	}
+
+	objects, err := c.client.QueryCalendar(ctx, c.calendarPath, &query)
+	if err != nil {
+		slog.Error("failed to query tagged caldav tasks", "calendar", c.calendarPath, "error", err)
+		return nil, fmt.Errorf("query tagged caldav tasks: %w", err)
+	}
+
+	return objects, nil
+}
+
+// objectsToTasks converts the VTODOs of calendar objects into tasks, keeping
+// only those accepted by keep when it is set.
+func objectsToTasks(objects []caldav.CalendarObject, keep func(*ical.Component) bool) []Task {
 	var tasks []Task
 	for _, obj := range objects {
 		if obj.Data == nil || obj.Data.Component == nil {
@@ -110,14 +231,18 @@ func (c *Client) QueryTasks(ctx context.Context, start, end time.Time) ([]Task,
 		}
 
 		for _, comp := range obj.Data.Component.Children {
-			if comp.Name != ical.CompToDo {
+			if comp.Name != ical.CompToDo || (keep != nil && !keep(comp)) {
 				continue
 			}
-			tasks = append(tasks, calendarObjectToTask(comp))
+			task := calendarObjectToTask(comp)
+			task.Path = obj.Path
+			task.ETag = obj.ETag
+			task.data = obj.Data
+			tasks = append(tasks, task)
 		}
 	}
 
-	return tasks, nil
+	return tasks
 }
 
 func calendarObjectToTask(comp *ical.Component) Task {
@@ -127,18 +252,39 @@ func calendarObjectToTask(comp *ical.Component) Task {
 	ruleID := textProp(comp, taskseedRuleProp)
 	occurrence := textProp(comp, taskseedOccProp)
 	status, _ := comp.Props.Text(ical.PropStatus)
+	idVersion, err := strconv.Atoi(textProp(comp, taskseedIDVProp))
+	if err != nil {
+		idVersion = 1
+	}
 	completed := strings.EqualFold(status, "COMPLETED") || comp.Props.Get(ical.PropCompleted) != nil
 
 	return Task{
-		UID:        uid,
-		Summary:    summary,
-		InstanceID: instanceID,
-		RuleID:     ruleID,
-		Occurrence: occurrence,
-		Completed:  completed,
+		UID:             uid,
+		Summary:         summary,
+		InstanceID:      instanceID,
+		IdentityVersion: idVersion,
+		RuleID:          ruleID,
+		Occurrence:      occurrence,
+		Completed:       completed,
+		Cancelled:       strings.EqualFold(status, "CANCELLED"),
+		CompletedAt:     completedAt(comp),
+		ContentHash:     textProp(comp, taskseedHashProp),
 	}
 }
 
+func completedAt(comp *ical.Component) *time.Time {
+	prop := comp.Props.Get(ical.PropCompleted)
+	if prop == nil {
+		return nil
+	}
+	t, err := prop.DateTime(time.UTC)
+	if err != nil {
+		slog.Warn("found invalid completion timestamp", "value", prop.Value, "error", err)
+		return nil
+	}
+	return &t
+}
+
 func textProp(comp *ical.Component, name string) string {
 	prop := comp.Props.Get(name)
 	if prop == nil {
@@ -147,30 +293,18 @@ func textProp(comp *ical.Component, name string) string {
 	return prop.Value
 }
 
-// CreateTask writes a new task resource to the calendar.
+// CreateTask writes a new task resource to the calendar. The write is guarded
+// by If-None-Match, so an existing resource is never overwritten; in that case
+// the returned error satisfies IsPreconditionFailed.
 func (c *Client) CreateTask(ctx context.Context, task NewTask) error {
 	todo := ical.NewComponent(ical.CompToDo)
 
 	todo.Props.SetText(ical.PropUID, task.UID)
-	todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
-	todo.Props.SetText(ical.PropSummary, task.Summary)
-	if task.Notes != "" {
-		todo.Props.SetText(ical.PropDescription, task.Notes)
-	}
-	if task.DateOnly {
-		prop := ical.NewProp(ical.PropDue)
-		prop.SetDate(task.Due)
-		todo.Props.Set(prop)
-	} else {
-		prop := ical.NewProp(ical.PropDue)
-		prop.SetDateTime(task.Due)
-		if task.Timezone != "" {
-			prop.Params.Set(ical.ParamTimezoneID, task.Timezone)
-		}
-		todo.Props.Set(prop)
-	}
-
+	setContent(todo, task)
 	todo.Props.SetText(taskseedIDProp, task.InstanceID)
+	if task.IdentityVersion > 0 {
+		todo.Props.SetText(taskseedIDVProp, strconv.Itoa(task.IdentityVersion))
+	}
 	todo.Props.SetText(taskseedRuleProp, task.RuleID)
 	todo.Props.SetText(taskseedOccProp, task.Occurrence)
 
@@ -178,10 +312,18 @@ func (c *Client) CreateTask(ctx context.Context, task NewTask) error {
 	cal.Props.SetText(ical.PropVersion, "2.0")
 	cal.Props.SetText(ical.PropProductID, "-//taskseed//EN")
 	cal.Children = append(cal.Children, todo)
+	if task.DueForm == DueFormZoned {
+		embedTimezone(cal, task.Due)
+	}
 
 	resource := task.InstanceID + ".ics"
 
-	_, err := c.client.PutCalendarObject(ctx, joinPath(c.calendarPath, resource), cal)
+	header := make(http.Header)
+	header.Set("If-None-Match", "*")
+	err := c.putCalendar(ctx, joinPath(c.calendarPath, resource), cal, header)
+	if IsPreconditionFailed(err) {
+		return fmt.Errorf("create caldav task: %w", err)
+	}
 	if err != nil {
 		slog.Error("failed to create caldav task", "calendar", c.calendarPath, "id", task.InstanceID, "error", err)
 		return fmt.Errorf("create caldav task: %w", err)
@@ -190,6 +332,158 @@ func (c *Client) CreateTask(ctx context.Context, task NewTask) error {
 	return nil
 }
 
+// UpdateTask rewrites the content of an existing task in place. The write is
+// guarded by the task's ETag, so concurrent changes by clients are never lost.
+func (c *Client) UpdateTask(ctx context.Context, existing Task, task NewTask) error {
+	if existing.data == nil || existing.Path == "" || existing.ETag == "" {
+		return fmt.Errorf("update caldav task %q: %w", existing.InstanceID, ErrNotUpdatable)
+	}
+
+	for _, todo := range existing.data.Children {
+		if todo.Name == ical.CompToDo {
+			setContent(todo, task)
+		}
+	}
+	if task.DueForm == DueFormZoned {
+		embedTimezone(existing.data, task.Due)
+	}
+
+	header := make(http.Header)
+	header.Set("If-Match", strconv.Quote(existing.ETag))
+	if err := c.putCalendar(ctx, existing.Path, existing.data, header); err != nil {
+		slog.Error("failed to update caldav task", "calendar", c.calendarPath, "id", existing.InstanceID, "error", err)
+		return fmt.Errorf("update caldav task: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTask removes an existing task, guarded by its ETag when known.
+func (c *Client) DeleteTask(ctx context.Context, existing Task) error {
+	if existing.Path == "" {
+		return fmt.Errorf("delete caldav task %q: %w", existing.InstanceID, ErrNotUpdatable)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodDelete, existing.Path, nil)
+	if err != nil {
+		return err
+	}
+	if existing.ETag != "" {
+		req.Header.Set("If-Match", strconv.Quote(existing.ETag))
+	}
+
+	if err := c.doDiscard(req); err != nil {
+		slog.Error("failed to delete caldav task", "calendar", c.calendarPath, "id", existing.InstanceID, "error", err)
+		return fmt.Errorf("delete caldav task: %w", err)
+	}
+
+	return nil
+}
+
+// CancelTask marks an existing task as cancelled, guarded by its ETag.
+func (c *Client) CancelTask(ctx context.Context, existing Task) error {
+	if existing.data == nil || existing.Path == "" || existing.ETag == "" {
+		return fmt.Errorf("cancel caldav task %q: %w", existing.InstanceID, ErrNotUpdatable)
+	}
+
+	for _, todo := range existing.data.Children {
+		if todo.Name == ical.CompToDo {
+			todo.Props.SetText(ical.PropStatus, "CANCELLED")
+			todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("If-Match", strconv.Quote(existing.ETag))
+	if err := c.putCalendar(ctx, existing.Path, existing.data, header); err != nil {
+		slog.Error("failed to cancel caldav task", "calendar", c.calendarPath, "id", existing.InstanceID, "error", err)
+		return fmt.Errorf("cancel caldav task: %w", err)
+	}
+
+	return nil
+}
+
+// RetagTask moves an existing task to another rule by rewriting its stored
+// rule, instance ID, and identity version properties. The UID and resource path are kept.
+func (c *Client) RetagTask(ctx context.Context, existing Task, ruleID, instanceID string, idVersion int) error {
+	if existing.data == nil || existing.Path == "" || existing.ETag == "" {
+		return fmt.Errorf("retag caldav task %q: %w", existing.InstanceID, ErrNotUpdatable)
+	}
+
+	for _, todo := range existing.data.Children {
+		if todo.Name == ical.CompToDo {
+			todo.Props.SetText(taskseedIDProp, instanceID)
+			todo.Props.SetText(taskseedIDVProp, strconv.Itoa(idVersion))
+			todo.Props.SetText(taskseedRuleProp, ruleID)
+			todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("If-Match", strconv.Quote(existing.ETag))
+	if err := c.putCalendar(ctx, existing.Path, existing.data, header); err != nil {
+		slog.Error("failed to retag caldav task", "calendar", c.calendarPath, "id", existing.InstanceID, "error", err)
+		return fmt.Errorf("retag caldav task: %w", err)
+	}
+
+	return nil
+}
+
+// SetContentHash records hash on an existing task without changing its content.
+// It adopts tasks created before content hashes were stored. The write is
+// guarded by the task's ETag.
+func (c *Client) SetContentHash(ctx context.Context, existing Task, hash string) error {
+	if existing.data == nil || existing.Path == "" || existing.ETag == "" {
+		return fmt.Errorf("set content hash of caldav task %q: %w", existing.InstanceID, ErrNotUpdatable)
+	}
+
+	for _, todo := range existing.data.Children {
+		if todo.Name == ical.CompToDo {
+			todo.Props.SetText(taskseedHashProp, hash)
+			todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("If-Match", strconv.Quote(existing.ETag))
+	if err := c.putCalendar(ctx, existing.Path, existing.data, header); err != nil {
+		slog.Error("failed to set caldav task content hash", "calendar", c.calendarPath, "id", existing.InstanceID, "error", err)
+		return fmt.Errorf("set content hash of caldav task: %w", err)
+	}
+
+	return nil
+}
+
+// setContent writes the rule-derived properties of a task onto a VTODO.
+func setContent(todo *ical.Component, task NewTask) {
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	todo.Props.SetText(ical.PropSummary, task.Summary)
+	if task.Notes != "" {
+		todo.Props.SetText(ical.PropDescription, task.Notes)
+	} else {
+		todo.Props.Del(ical.PropDescription)
+	}
+	prop := ical.NewProp(ical.PropDue)
+	switch task.DueForm {
+	case DueFormDate:
+		prop.SetDate(task.Due)
+	case DueFormUTC:
+		prop.SetDateTime(task.Due.UTC())
+	case DueFormFloating:
+		prop.SetValueType(ical.ValueDateTime)
+		prop.Value = task.Due.Format(floatingLayout)
+	default:
+		prop.SetDateTime(task.Due)
+		if task.Timezone != "" && task.Due.Location() != time.UTC {
+			prop.Params.Set(ical.ParamTimezoneID, task.Timezone)
+		}
+	}
+	todo.Props.Set(prop)
+	if task.ContentHash != "" {
+		todo.Props.SetText(taskseedHashProp, task.ContentHash)
+	}
+}
+
 func joinPath(base, name string) string {
 	if strings.HasSuffix(base, "/") {
 		return base + name
@@ -0,0 +1,241 @@
+package caldav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/dav/", http.DefaultTransport)
+	require.NoError(t, err)
+
+	calendar, err := client.WithCalendar(server.URL + "/dav/tasks/")
+	require.NoError(t, err)
+
+	return calendar
+}
+
+func existingTask() Task {
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, "abc")
+	todo.Props.SetText(ical.PropSummary, "Old title")
+	todo.Props.SetText(ical.PropCategories, "home")
+	todo.Props.SetText(taskseedIDProp, "abc")
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//taskseed//EN")
+	cal.Children = append(cal.Children, todo)
+
+	return Task{UID: "abc", InstanceID: "abc", Path: "/dav/tasks/abc.ics", ETag: "v1", data: cal}
+}
+
+func TestUpdateTaskSendsIfMatchAndKeepsForeignProps(t *testing.T) {
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/dav/tasks/abc.ics", r.URL.Path)
+		assert.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.UpdateTask(context.Background(), existingTask(), NewTask{
+		Summary:     "New title",
+		Due:         time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC),
+		ContentHash: "hash",
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, body, "SUMMARY:New title")
+	assert.Contains(t, body, "CATEGORIES:home")
+	assert.Contains(t, body, "X-TASKSEED-HASH;VALUE=TEXT:hash")
+}
+
+func TestUpdateTaskReportsPreconditionFailed(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+
+	err := client.UpdateTask(context.Background(), existingTask(), NewTask{Summary: "New title"})
+
+	require.Error(t, err)
+	assert.True(t, IsPreconditionFailed(err))
+}
+
+func TestUpdateTaskWithoutETagIsRejected(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("unexpected request")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	task := existingTask()
+	task.ETag = ""
+
+	err := client.UpdateTask(context.Background(), task, NewTask{Summary: "New title"})
+
+	assert.ErrorIs(t, err, ErrNotUpdatable)
+}
+
+func TestCreateTaskNeverOverwritesExistingResource(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/dav/tasks/def.ics", r.URL.Path)
+		assert.Equal(t, "*", r.Header.Get("If-None-Match"))
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+
+	err := client.CreateTask(context.Background(), NewTask{
+		UID:        "def",
+		Summary:    "Title",
+		Due:        time.Date(2023, time.March, 1, 9, 0, 0, 0, time.UTC),
+		InstanceID: "def",
+	})
+
+	require.Error(t, err)
+	assert.True(t, IsPreconditionFailed(err))
+}
+
+func TestRetagTaskRewritesRuleAndInstanceID(t *testing.T) {
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/dav/tasks/abc.ics", r.URL.Path)
+		assert.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.RetagTask(context.Background(), existingTask(), "new_rule", "def", 2)
+
+	require.NoError(t, err)
+	assert.Contains(t, body, "UID:abc")
+	assert.Contains(t, body, "X-TASKSEED-ID;VALUE=TEXT:def")
+	assert.Contains(t, body, "X-TASKSEED-IDV;VALUE=TEXT:2")
+	assert.Contains(t, body, "X-TASKSEED-RULE;VALUE=TEXT:new_rule")
+}
+
+func TestDeleteTaskSendsIfMatch(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/dav/tasks/abc.ics", r.URL.Path)
+		assert.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	require.NoError(t, client.DeleteTask(context.Background(), existingTask()))
+}
+
+func TestDeleteTaskReportsPreconditionFailed(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+
+	err := client.DeleteTask(context.Background(), existingTask())
+
+	require.Error(t, err)
+	assert.True(t, IsPreconditionFailed(err))
+}
+
+func TestCancelTaskSetsStatusAndKeepsContent(t *testing.T) {
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/dav/tasks/abc.ics", r.URL.Path)
+		assert.Equal(t, `"v1"`, r.Header.Get("If-Match"))
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.CancelTask(context.Background(), existingTask())
+
+	require.NoError(t, err)
+	assert.Contains(t, body, "STATUS:CANCELLED")
+	assert.Contains(t, body, "SUMMARY:Old title")
+}
+
+func TestCancelTaskWithoutETagIsRejected(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("unexpected request")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	task := existingTask()
+	task.ETag = ""
+
+	err := client.CancelTask(context.Background(), task)
+
+	assert.ErrorIs(t, err, ErrNotUpdatable)
+}
+
+func TestProbeReportsUnauthorized(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PROPFIND", r.Method)
+		assert.Equal(t, "0", r.Header.Get("Depth"))
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	err := client.Probe(context.Background())
+
+	require.Error(t, err)
+	assert.True(t, IsUnauthorized(err))
+	assert.False(t, IsPreconditionFailed(err))
+}
+
+func TestCreateCalendarSendsMkcalendarForTasks(t *testing.T) {
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "MKCALENDAR", r.Method)
+		assert.Equal(t, "/dav/tasks/", r.URL.Path)
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	err := client.CreateCalendar(context.Background(), "Chores & errands", "#3b82f6")
+
+	require.NoError(t, err)
+	assert.Contains(t, body, `<mkcalendar xmlns="urn:ietf:params:xml:ns:caldav"><set xmlns="DAV:"><prop xmlns="DAV:">`)
+	assert.Contains(t, body, `<displayname xmlns="DAV:">Chores &amp; errands</displayname>`)
+	assert.Contains(t, body, `<calendar-color xmlns="http://apple.com/ns/ical/">#3b82f6</calendar-color>`)
+	assert.Contains(t, body, `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="VTODO"></comp>`)
+}
+
+func TestSetContentWritesDueForm(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	due := time.Date(2024, time.June, 1, 9, 0, 0, 0, loc)
+
+	tests := []struct {
+		form  DueForm
+		want  string
+		param string
+	}{
+		{form: DueFormZoned, want: "20240601T090000", param: "Europe/Berlin"},
+		{form: DueFormUTC, want: "20240601T070000Z"},
+		{form: DueFormFloating, want: "20240601T090000"},
+		{form: DueFormDate, want: "20240601"},
+	}
+
+	for _, tt := range tests {
+		todo := ical.NewComponent(ical.CompToDo)
+		setContent(todo, NewTask{Summary: "Title", Due: due, DueForm: tt.form, Timezone: "Europe/Berlin"})
+
+		prop := todo.Props.Get(ical.PropDue)
+		assert.Equal(t, tt.want, prop.Value)
+		assert.Equal(t, tt.param, prop.Params.Get(ical.ParamTimezoneID))
+	}
+}
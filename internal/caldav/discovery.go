@@ -0,0 +1,72 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-ical"
+)
+
+// TaskList describes a calendar collection that can hold tasks.
+type TaskList struct {
+	Name        string
+	Description string
+	Path        string
+	URL         string
+}
+
+// FindTaskLists discovers the current user's calendar collections that support
+// VTODO by following current-user-principal and calendar-home-set.
+func (c *Client) FindTaskLists(ctx context.Context) ([]TaskList, error) {
+	principal, err := c.client.FindCurrentUserPrincipal(ctx)
+	if err != nil {
+		slog.Error("failed to find current user principal", "error", err)
+		return nil, fmt.Errorf("find current user principal: %w", err)
+	}
+
+	homeSet, err := c.client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		slog.Error("failed to find calendar home set", "principal", principal, "error", err)
+		return nil, fmt.Errorf("find calendar home set: %w", err)
+	}
+
+	calendars, err := c.client.FindCalendars(ctx, homeSet)
+	if err != nil {
+		slog.Error("failed to find calendars", "home_set", homeSet, "error", err)
+		return nil, fmt.Errorf("find calendars: %w", err)
+	}
+
+	var lists []TaskList
+	for _, cal := range calendars {
+		if !supportsTasks(cal.SupportedComponentSet) {
+			continue
+		}
+		lists = append(lists, TaskList{
+			Name:        cal.Name,
+			Description: cal.Description,
+			Path:        cal.Path,
+			URL:         c.endpoint.ResolveReference(&url.URL{Path: cal.Path}).String(),
+		})
+	}
+
+	slices.SortFunc(lists, func(a, b TaskList) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return lists, nil
+}
+
+// supportsTasks reports whether a supported-calendar-component-set allows
+// VTODO. An empty set means the server did not restrict the components.
+func supportsTasks(components []string) bool {
+	if len(components) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(components, func(name string) bool {
+		return strings.EqualFold(name, ical.CompToDo)
+	})
+}
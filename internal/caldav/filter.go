@@ -0,0 +1,47 @@
+package caldav
+
+import (
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// inWindow reports whether a VTODO overlaps [start, end) following the
+// time-range rules of RFC 4791 section 9.9, without DURATION support.
+// Date and floating values are interpreted in start's location.
+func inWindow(comp *ical.Component, start, end time.Time) bool {
+	loc := start.Location()
+	dtstart, hasStart := propTime(comp, ical.PropDateTimeStart, loc)
+	due, hasDue := propTime(comp, ical.PropDue, loc)
+	completed, hasCompleted := propTime(comp, ical.PropCompleted, loc)
+	created, hasCreated := propTime(comp, ical.PropCreated, loc)
+
+	switch {
+	case hasStart && hasDue:
+		return (!start.After(dtstart) || start.Before(due)) && (end.After(dtstart) || !end.Before(due))
+	case hasStart:
+		return !start.After(dtstart) && end.After(dtstart)
+	case hasDue:
+		return start.Before(due) && !end.Before(due)
+	case hasCompleted && hasCreated:
+		return (!start.After(created) || !start.After(completed)) && (!end.Before(created) || !end.Before(completed))
+	case hasCompleted:
+		return !start.After(completed) && !end.Before(completed)
+	case hasCreated:
+		return end.After(created)
+	default:
+		return true
+	}
+}
+
+func propTime(comp *ical.Component, name string, loc *time.Location) (time.Time, bool) {
+	prop := comp.Props.Get(name)
+	if prop == nil {
+		return time.Time{}, false
+	}
+	t, err := prop.DateTime(loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,41 @@
+package caldav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInWindowFollowsVTODOTimeRangeRules(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		props map[string]time.Time
+		want  bool
+	}{
+		{name: "no dates", want: true},
+		{name: "due inside", props: map[string]time.Time{ical.PropDue: at(time.January, 10)}, want: true},
+		{name: "due after", props: map[string]time.Time{ical.PropDue: at(time.March, 1)}, want: false},
+		{name: "start before due inside", props: map[string]time.Time{ical.PropDateTimeStart: at(time.January, 1).AddDate(0, -1, 0), ical.PropDue: at(time.January, 5)}, want: true},
+		{name: "start after", props: map[string]time.Time{ical.PropDateTimeStart: at(time.March, 1)}, want: false},
+		{name: "completed before", props: map[string]time.Time{ical.PropCompleted: at(time.January, 1).AddDate(-1, 0, 0)}, want: false},
+		{name: "created before", props: map[string]time.Time{ical.PropCreated: at(time.January, 1).AddDate(-1, 0, 0)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := ical.NewComponent(ical.CompToDo)
+			for name, value := range tt.props {
+				todo.Props.SetDateTime(name, value)
+			}
+			assert.Equal(t, tt.want, inWindow(todo, start, end))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+)
+
+// mkcalendarRequest is the body of an RFC 4791 MKCALENDAR request.
+type mkcalendarRequest struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav mkcalendar"`
+	Set     mkcalendarSet `xml:"DAV: set"`
+}
+
+type mkcalendarSet struct {
+	Prop mkcalendarProp `xml:"DAV: prop"`
+}
+
+type mkcalendarProp struct {
+	DisplayName  string              `xml:"DAV: displayname,omitempty"`
+	Color        string              `xml:"http://apple.com/ns/ical/ calendar-color,omitempty"`
+	ComponentSet supportedComponents `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+}
+
+type supportedComponents struct {
+	Comps []component `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type component struct {
+	Name string `xml:"name,attr"`
+}
+
+// CreateCalendar creates the client's calendar collection restricted to VTODO,
+// with an optional display name and color (e.g. #3b82f6).
+func (c *Client) CreateCalendar(ctx context.Context, displayName, color string) error {
+	body, err := xml.Marshal(mkcalendarRequest{
+		Set: mkcalendarSet{Prop: mkcalendarProp{
+			DisplayName:  displayName,
+			Color:        color,
+			ComponentSet: supportedComponents{Comps: []component{{Name: "VTODO"}}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("encode mkcalendar request: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "MKCALENDAR", c.calendarPath, bytes.NewReader(append([]byte(xml.Header), body...)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	if err := c.doDiscard(req); err != nil {
+		slog.Error("failed to create calendar", "calendar", c.calendarPath, "error", err)
+		return fmt.Errorf("create calendar: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/emersion/go-ical"
+)
+
+// ErrNotUpdatable reports a task that lacks the path, ETag, or data needed for a guarded write.
+var ErrNotUpdatable = errors.New("task cannot be updated safely")
+
+// StatusError reports an unexpected HTTP status returned by the server.
+type StatusError struct {
+	Method string
+	Path   string
+	Code   int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.Code, http.StatusText(e.Code))
+}
+
+// IsPreconditionFailed reports whether err stems from a failed If-Match or If-None-Match condition.
+func IsPreconditionFailed(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusPreconditionFailed
+}
+
+// IsNotFound reports whether err stems from a missing resource.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether err stems from the server rejecting the credentials.
+func IsUnauthorized(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden)
+}
+
+// Probe checks that the calendar collection is reachable with the client's
+// credentials. Failed requests are reported as a StatusError.
+func (c *Client) Probe(ctx context.Context) error {
+	req, err := c.newRequest(ctx, "PROPFIND", c.calendarPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Depth", "0")
+
+	return c.doDiscard(req)
+}
+
+func (c *Client) putCalendar(ctx context.Context, path string, cal *ical.Calendar, header http.Header) error {
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return fmt.Errorf("encode calendar: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ical.MIMEType)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	return c.doDiscard(req)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	target := c.endpoint.ResolveReference(&url.URL{Path: path})
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("build %s request: %w", method, err)
+	}
+	return req, nil
+}
+
+// doDiscard sends a request and drains its response, returning a StatusError for non-2xx responses.
+func (c *Client) doDiscard(req *http.Request) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{Method: req.Method, Path: req.URL.Path, Code: resp.StatusCode}
+	}
+
+	return nil
+}
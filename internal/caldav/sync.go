@@ -0,0 +1,310 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// maxSyncRounds bounds how often a truncated sync-collection report is continued.
+const maxSyncRounds = 100
+
+// multiGetBatch bounds how many objects a single calendar-multiget requests.
+const multiGetBatch = 100
+
+// errInvalidSyncToken reports a sync token the server no longer accepts.
+var errInvalidSyncToken = errors.New("sync token rejected")
+
+// SyncCache is the local copy of a task list kept between incremental syncs.
+type SyncCache struct {
+	Token   string                  `json:"token"`
+	Objects map[string]CachedObject `json:"objects"`
+}
+
+// CachedObject is a calendar object as last seen on the server.
+type CachedObject struct {
+	ETag string `json:"etag"`
+	Data string `json:"data"`
+}
+
+// SyncTasks brings cache up to date with an RFC 6578 sync-collection report and
+// returns the cached VTODO tasks within the time range. A sync token rejected
+// by the server triggers a full resynchronization. Other failures keep the
+// cache at its previous token and are returned so callers can fall back to
+// QueryTasks.
+func (c *Client) SyncTasks(ctx context.Context, start, end time.Time, cache *SyncCache) ([]Task, error) {
+	token := cache.Token
+	err := c.syncCache(ctx, cache)
+	if token != "" && errors.Is(err, errInvalidSyncToken) {
+		slog.Warn("resetting rejected sync token", "calendar", c.calendarPath, "error", err)
+		*cache = SyncCache{}
+		err = c.syncCache(ctx, cache)
+	}
+	if err != nil {
+		slog.Error("failed to sync caldav tasks", "calendar", c.calendarPath, "error", err)
+		return nil, fmt.Errorf("sync caldav tasks: %w", err)
+	}
+
+	var tasks []Task
+	for path, obj := range cache.Objects {
+		cal, err := ical.NewDecoder(strings.NewReader(obj.Data)).Decode()
+		if err != nil {
+			slog.Warn("skipping undecodable cached object", "path", path, "error", err)
+			continue
+		}
+		for _, comp := range cal.Children {
+			if comp.Name != ical.CompToDo || !inWindow(comp, start, end) {
+				continue
+			}
+			task := calendarObjectToTask(comp)
+			task.Path = path
+			task.ETag = obj.ETag
+			task.data = cal
+			tasks = append(tasks, task)
+		}
+	}
+
+	slices.SortFunc(tasks, func(a, b Task) int { return strings.Compare(a.Path, b.Path) })
+
+	return tasks, nil
+}
+
+// syncCache applies the changes since the cached token to cache. The new token
+// is only stored once every changed object has been fetched, so that objects
+// missed by a failed run are reported again by the next one.
+func (c *Client) syncCache(ctx context.Context, cache *SyncCache) error {
+	if cache.Objects == nil {
+		cache.Objects = make(map[string]CachedObject)
+	}
+
+	token := cache.Token
+	changed := make(map[string]struct{})
+	for range maxSyncRounds {
+		report, err := c.syncCollection(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range report.deleted {
+			delete(cache.Objects, path)
+		}
+		for path, etag := range report.changed {
+			if cached, ok := cache.Objects[path]; ok && cached.ETag == etag && etag != "" {
+				continue
+			}
+			changed[path] = struct{}{}
+		}
+		token = report.token
+
+		if !report.truncated {
+			slog.Debug("synced caldav collection", "calendar", c.calendarPath, "changed", len(changed), "deleted", len(report.deleted))
+			if err := c.fetchObjects(ctx, slices.Sorted(maps.Keys(changed)), cache); err != nil {
+				return err
+			}
+			cache.Token = token
+			return nil
+		}
+	}
+
+	return fmt.Errorf("sync-collection still truncated after %d rounds", maxSyncRounds)
+}
+
+// fetchObjects downloads the given objects with calendar-multiget and stores
+// them in cache. It fails when any of them could not be stored.
+func (c *Client) fetchObjects(ctx context.Context, paths []string, cache *SyncCache) error {
+	missing := 0
+	for len(paths) > 0 {
+		batch := paths[:min(multiGetBatch, len(paths))]
+		paths = paths[len(batch):]
+
+		objects, err := c.client.MultiGetCalendar(ctx, c.calendarPath, &caldav.CalendarMultiGet{
+			Paths:       batch,
+			CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+		})
+		if err != nil {
+			return fmt.Errorf("fetch changed objects: %w", err)
+		}
+
+		stored := 0
+		for _, obj := range objects {
+			if obj.Data == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := ical.NewEncoder(&buf).Encode(obj.Data); err != nil {
+				slog.Warn("skipping unencodable object", "path", obj.Path, "error", err)
+				continue
+			}
+			cache.Objects[obj.Path] = CachedObject{ETag: obj.ETag, Data: buf.String()}
+			stored++
+		}
+		missing += len(batch) - stored
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("fetch changed objects: %d of them could not be stored", missing)
+	}
+
+	return nil
+}
+
+// syncReport summarizes one sync-collection response.
+type syncReport struct {
+	token     string
+	changed   map[string]string
+	deleted   []string
+	truncated bool
+}
+
+type syncCollectionRequest struct {
+	XMLName   xml.Name `xml:"DAV: sync-collection"`
+	SyncToken string   `xml:"DAV: sync-token"`
+	SyncLevel string   `xml:"DAV: sync-level"`
+	Prop      syncProp `xml:"DAV: prop"`
+}
+
+type syncProp struct {
+	GetETag *struct{} `xml:"DAV: getetag"`
+}
+
+type syncMultiStatus struct {
+	Responses []syncResponse `xml:"DAV: response"`
+	SyncToken string         `xml:"DAV: sync-token"`
+}
+
+type syncResponse struct {
+	Href      string         `xml:"DAV: href"`
+	Status    string         `xml:"DAV: status"`
+	PropStats []syncPropStat `xml:"DAV: propstat"`
+}
+
+type syncPropStat struct {
+	Status string       `xml:"DAV: status"`
+	Prop   syncPropETag `xml:"DAV: prop"`
+}
+
+type syncPropETag struct {
+	ETag string `xml:"DAV: getetag"`
+}
+
+type syncError struct {
+	XMLName        xml.Name  `xml:"DAV: error"`
+	ValidSyncToken *struct{} `xml:"DAV: valid-sync-token"`
+}
+
+func (c *Client) syncCollection(ctx context.Context, token string) (syncReport, error) {
+	body, err := xml.Marshal(syncCollectionRequest{
+		SyncToken: token,
+		SyncLevel: "1",
+		Prop:      syncProp{GetETag: &struct{}{}},
+	})
+	if err != nil {
+		return syncReport{}, fmt.Errorf("encode sync-collection request: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "REPORT", c.calendarPath, bytes.NewReader(append([]byte(xml.Header), body...)))
+	if err != nil {
+		return syncReport{}, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return syncReport{}, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		statusErr := &StatusError{Method: req.Method, Path: req.URL.Path, Code: resp.StatusCode}
+		if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusConflict) && rejectsSyncToken(resp.Body) {
+			return syncReport{}, fmt.Errorf("%w: %w", errInvalidSyncToken, statusErr)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return syncReport{}, statusErr
+	}
+
+	var ms syncMultiStatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return syncReport{}, fmt.Errorf("decode sync-collection response: %w", err)
+	}
+	if ms.SyncToken == "" {
+		return syncReport{}, fmt.Errorf("sync-collection response without sync-token")
+	}
+
+	report := syncReport{token: ms.SyncToken, changed: make(map[string]string)}
+	for _, r := range ms.Responses {
+		path := hrefPath(r.Href)
+		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(c.calendarPath, "/") {
+			report.truncated = report.truncated || statusCode(r.Status) == http.StatusInsufficientStorage
+			continue
+		}
+		if statusCode(r.Status) == http.StatusNotFound {
+			report.deleted = append(report.deleted, path)
+			continue
+		}
+		for _, ps := range r.PropStats {
+			if statusCode(ps.Status) == http.StatusOK {
+				report.changed[path] = unquoteETag(ps.Prop.ETag)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// rejectsSyncToken reports whether an error body carries the RFC 6578
+// DAV:valid-sync-token precondition.
+func rejectsSyncToken(body io.Reader) bool {
+	var e syncError
+	if err := xml.NewDecoder(body).Decode(&e); err != nil {
+		return false
+	}
+	return e.ValidSyncToken != nil
+}
+
+// hrefPath returns the decoded path of an href, which may be an absolute URL.
+func hrefPath(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return href
+	}
+	return u.Path
+}
+
+// unquoteETag strips the quotes of an entity tag, matching the ETags that
+// go-webdav reports for fetched objects.
+func unquoteETag(etag string) string {
+	if unquoted, err := strconv.Unquote(strings.TrimSpace(etag)); err == nil {
+		return unquoted
+	}
+	return etag
+}
+
+// statusCode extracts the code from a status line such as "HTTP/1.1 404 Not Found".
+func statusCode(status string) int {
+	fields := strings.Fields(status)
+	if len(fields) < 2 {
+		return 0
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0
+	}
+	return code
+}
@@ -0,0 +1,244 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func todoData(uid, summary, due string) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VTODO",
+		"UID:" + uid,
+		"X-TASKSEED-ID:" + uid,
+		"DTSTAMP:20240101T000000Z",
+		"SUMMARY:" + summary,
+		"DUE:" + due,
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+// fakeSyncServer serves sync-collection and calendar-multiget reports from
+// changes, which maps a sync token to the next token and the changed objects.
+// Objects with an empty ETag are reported as deleted; fetched objects always
+// carry the ETag "1".
+func fakeSyncServer(t *testing.T, objects map[string]string, changes map[string]syncChange) (http.HandlerFunc, *[]string) {
+	var fetched []string
+	return func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "REPORT", r.Method)
+		raw, _ := io.ReadAll(r.Body)
+		body := string(raw)
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+
+		var out strings.Builder
+		out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+		if strings.Contains(body, "sync-collection") {
+			var token string
+			if m := syncTokenPattern.FindStringSubmatch(body); m != nil {
+				token = m[1]
+			}
+			change, ok := changes[token]
+			if !ok {
+				t.Fatalf("unexpected sync token %q", token)
+			}
+			for path, etag := range change.etags {
+				if etag == "" {
+					fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, path)
+					continue
+				}
+				fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>"%s"</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, etag)
+			}
+			fmt.Fprintf(&out, `<d:sync-token>%s</d:sync-token>`, change.next)
+		} else {
+			for path, data := range objects {
+				if !strings.Contains(body, path) {
+					continue
+				}
+				fetched = append(fetched, path)
+				fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>"1"</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, data)
+			}
+		}
+		out.WriteString(`</d:multistatus>`)
+		_, _ = io.WriteString(w, out.String())
+	}, &fetched
+}
+
+type syncChange struct {
+	next  string
+	etags map[string]string
+}
+
+var syncTokenPattern = regexp.MustCompile(`<sync-token[^>]*>([^<]*)</sync-token>`)
+
+func TestSyncTasksFetchesOnlyChangedObjects(t *testing.T) {
+	objects := map[string]string{
+		"/dav/tasks/a.ics": todoData("a", "A", "20240110T090000Z"),
+		"/dav/tasks/b.ics": todoData("b", "B", "20240111T090000Z"),
+		"/dav/tasks/c.ics": todoData("c", "C", "20250101T090000Z"),
+	}
+	changes := map[string]syncChange{
+		"":   {next: "t1", etags: map[string]string{"/dav/tasks/a.ics": "1", "/dav/tasks/b.ics": "1", "/dav/tasks/c.ics": "1"}},
+		"t1": {next: "t2", etags: map[string]string{"/dav/tasks/b.ics": "", "/dav/tasks/a.ics": "1"}},
+	}
+	handler, fetched := fakeSyncServer(t, objects, changes)
+	client := newTestClient(t, handler)
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	var cache SyncCache
+
+	tasks, err := client.SyncTasks(context.Background(), start, end, &cache)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "a", tasks[0].UID)
+	assert.Equal(t, "b", tasks[1].UID)
+	assert.Equal(t, "t1", cache.Token)
+	assert.Len(t, cache.Objects, 3)
+	assert.Len(t, *fetched, 3)
+
+	*fetched = nil
+	tasks, err = client.SyncTasks(context.Background(), start, end, &cache)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "a", tasks[0].UID)
+	assert.Equal(t, `1`, tasks[0].ETag)
+	assert.Equal(t, "t2", cache.Token)
+	assert.Empty(t, *fetched)
+}
+
+func TestSyncTasksResetsRejectedToken(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		raw, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(raw), "stale") {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<?xml version="1.0"?><d:error xmlns:d="DAV:"><d:valid-sync-token/></d:error>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = io.WriteString(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"><d:sync-token>fresh</d:sync-token></d:multistatus>`)
+	})
+
+	cache := SyncCache{Token: "stale", Objects: map[string]CachedObject{"/dav/tasks/gone.ics": {ETag: "1"}}}
+	tasks, err := client.SyncTasks(context.Background(), time.Now(), time.Now().Add(time.Hour), &cache)
+
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+	assert.Equal(t, "fresh", cache.Token)
+	assert.Empty(t, cache.Objects)
+	assert.Equal(t, 2, requests)
+}
+
+func TestSyncTasksKeepsCacheOnOtherFailures(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	cache := SyncCache{Token: "t1", Objects: map[string]CachedObject{"/dav/tasks/a.ics": {ETag: "1"}}}
+	_, err := client.SyncTasks(context.Background(), time.Now(), time.Now().Add(time.Hour), &cache)
+
+	require.Error(t, err)
+	assert.Equal(t, "t1", cache.Token)
+	assert.Len(t, cache.Objects, 1)
+	assert.Equal(t, 1, requests)
+}
+
+func TestSyncTasksKeepsTokenUntilEveryObjectIsStored(t *testing.T) {
+	objects := map[string]string{"/dav/tasks/a.ics": todoData("a", "A", "20240110T090000Z")}
+	changes := map[string]syncChange{
+		"": {next: "t1", etags: map[string]string{"/dav/tasks/a.ics": "1", "/dav/tasks/b.ics": "1"}},
+	}
+	handler, _ := fakeSyncServer(t, objects, changes)
+	client := newTestClient(t, handler)
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	var cache SyncCache
+
+	_, err := client.SyncTasks(context.Background(), start, end, &cache)
+	require.Error(t, err)
+	assert.Empty(t, cache.Token)
+
+	objects["/dav/tasks/b.ics"] = todoData("b", "B", "20240111T090000Z")
+	tasks, err := client.SyncTasks(context.Background(), start, end, &cache)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "t1", cache.Token)
+}
+
+// writeQueryResponse answers a calendar-query with one calendar object per entry of objects.
+func writeQueryResponse(w http.ResponseWriter, objects map[string]string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+	for path, data := range objects {
+		fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>"1"</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, data)
+	}
+	out.WriteString(`</d:multistatus>`)
+	_, _ = io.WriteString(w, out.String())
+}
+
+func TestQueryTaggedTasksFiltersWindowOnClient(t *testing.T) {
+	objects := map[string]string{
+		"/dav/tasks/in.ics":  todoData("in", "In", "20240110T090000Z"),
+		"/dav/tasks/out.ics": todoData("out", "Out", "20250110T090000Z"),
+		"/dav/tasks/foreign.ics": strings.Replace(todoData("foreign", "Foreign", "20240110T090000Z"),
+			"X-TASKSEED-ID:foreign\r\n", "", 1),
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(raw), `name="X-TASKSEED-ID"`)
+		assert.NotContains(t, string(raw), "time-range")
+		writeQueryResponse(w, objects)
+	})
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	tasks, err := client.QueryTaggedTasks(context.Background(), start, end)
+
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "in", tasks[0].UID)
+}
+
+func TestQueryOpenTaggedTasksIgnoresDates(t *testing.T) {
+	objects := map[string]string{
+		"/dav/tasks/old.ics":    todoData("old", "Old", "20200110T090000Z"),
+		"/dav/tasks/future.ics": todoData("future", "Future", "20300110T090000Z"),
+		"/dav/tasks/done.ics": strings.Replace(todoData("done", "Done", "20240110T090000Z"),
+			"END:VTODO", "COMPLETED:20240110T100000Z\r\nEND:VTODO", 1),
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(raw), `name="X-TASKSEED-ID"`)
+		assert.NotContains(t, string(raw), "time-range")
+		writeQueryResponse(w, objects)
+	})
+
+	tasks, err := client.QueryOpenTaggedTasks(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	uids := []string{tasks[0].UID, tasks[1].UID}
+	assert.ElementsMatch(t, []string{"old", "future"}, uids)
+}
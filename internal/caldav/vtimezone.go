@@ -0,0 +1,93 @@
+package caldav
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// timezoneSpan is how far around a due time the embedded VTIMEZONE describes
+// offset transitions, so that clients can also move the task within that span.
+const timezoneSpan = 1
+
+// embedTimezone replaces the VTIMEZONE for the location of due, which DUE
+// references by TZID. UTC values need no VTIMEZONE.
+func embedTimezone(cal *ical.Calendar, due time.Time) {
+	loc := due.Location()
+	if loc == nil || loc == time.UTC {
+		return
+	}
+
+	children := []*ical.Component{newVTimezone(loc, due.AddDate(-timezoneSpan, 0, 0), due.AddDate(timezoneSpan, 0, 0))}
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone && textProp(child, ical.PropTimezoneID) == loc.String() {
+			continue
+		}
+		children = append(children, child)
+	}
+	cal.Children = children
+}
+
+// newVTimezone describes loc between from and until with one observance per
+// offset transition, starting with the observance in effect at from.
+func newVTimezone(loc *time.Location, from, until time.Time) *ical.Component {
+	tz := ical.NewComponent(ical.CompTimezone)
+	tz.Props.SetText(ical.PropTimezoneID, loc.String())
+
+	t := from.In(loc)
+	start, end := t.ZoneBounds()
+	_, offset := t.Zone()
+	prevOffset := offset
+	if !start.IsZero() {
+		_, prevOffset = start.Add(-time.Second).Zone()
+	} else {
+		start = time.Date(1970, time.January, 1, 0, 0, 0, 0, loc)
+	}
+	tz.Children = append(tz.Children, newObservance(start, prevOffset))
+
+	for !end.IsZero() && end.Before(until) {
+		_, offset = end.Add(-time.Second).Zone()
+		tz.Children = append(tz.Children, newObservance(end, offset))
+		_, end = end.ZoneBounds()
+	}
+
+	return tz
+}
+
+// newObservance describes the zone starting at t, which follows a zone with offsetFrom.
+func newObservance(t time.Time, offsetFrom int) *ical.Component {
+	name, offsetTo := t.Zone()
+
+	kind := ical.CompTimezoneStandard
+	if t.IsDST() {
+		kind = ical.CompTimezoneDaylight
+	}
+
+	obs := ical.NewComponent(kind)
+	// Observances start at the local time in effect before the transition.
+	dtstart := ical.NewProp(ical.PropDateTimeStart)
+	dtstart.Value = t.In(time.FixedZone("", offsetFrom)).Format(floatingLayout)
+	obs.Props.Set(dtstart)
+	obs.Props.Set(&ical.Prop{Name: ical.PropTimezoneOffsetFrom, Params: make(ical.Params), Value: formatOffset(offsetFrom)})
+	obs.Props.Set(&ical.Prop{Name: ical.PropTimezoneOffsetTo, Params: make(ical.Params), Value: formatOffset(offsetTo)})
+	if name != "" {
+		obs.Props.SetText(ical.PropTimezoneName, name)
+	}
+
+	return obs
+}
+
+// formatOffset formats a UTC offset in seconds as an RFC 5545 UTC-OFFSET.
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	hours, minutes, seconds := offset/3600, offset/60%60, offset%60
+	if seconds != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, hours, minutes)
+}
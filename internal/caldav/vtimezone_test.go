@@ -0,0 +1,106 @@
+package caldav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVTimezoneListsTransitionsInRange(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tz := newVTimezone(loc, time.Date(2023, time.March, 1, 0, 0, 0, 0, loc), time.Date(2025, time.March, 1, 0, 0, 0, 0, loc))
+
+	assert.Equal(t, "Europe/Berlin", textProp(tz, ical.PropTimezoneID))
+	require.Len(t, tz.Children, 5)
+
+	first := tz.Children[0]
+	assert.Equal(t, ical.CompTimezoneStandard, first.Name)
+	assert.Equal(t, "20221030T030000", first.Props.Get(ical.PropDateTimeStart).Value)
+	assert.Equal(t, "+0200", first.Props.Get(ical.PropTimezoneOffsetFrom).Value)
+	assert.Equal(t, "+0100", first.Props.Get(ical.PropTimezoneOffsetTo).Value)
+
+	spring := tz.Children[1]
+	assert.Equal(t, ical.CompTimezoneDaylight, spring.Name)
+	assert.Equal(t, "20230326T020000", spring.Props.Get(ical.PropDateTimeStart).Value)
+	assert.Equal(t, "+0100", spring.Props.Get(ical.PropTimezoneOffsetFrom).Value)
+	assert.Equal(t, "+0200", spring.Props.Get(ical.PropTimezoneOffsetTo).Value)
+	assert.Equal(t, "CEST", textProp(spring, ical.PropTimezoneName))
+}
+
+func TestEmbedTimezoneReplacesMatchingTimezoneOnly(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	cal := existingTask().data
+	stale := ical.NewComponent(ical.CompTimezone)
+	stale.Props.SetText(ical.PropTimezoneID, "America/New_York")
+	foreign := ical.NewComponent(ical.CompTimezone)
+	foreign.Props.SetText(ical.PropTimezoneID, "Europe/Paris")
+	cal.Children = append(cal.Children, stale, foreign)
+
+	embedTimezone(cal, time.Date(2024, time.June, 1, 9, 0, 0, 0, loc))
+
+	var zones []string
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			zones = append(zones, textProp(child, ical.PropTimezoneID))
+		}
+	}
+	assert.ElementsMatch(t, []string{"America/New_York", "Europe/Paris"}, zones)
+	assert.NotSame(t, stale, cal.Children[0])
+	assert.NotEmpty(t, cal.Children[0].Children)
+}
+
+func TestCreateTaskEmbedsTimezoneOfDue(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	var body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	err = client.CreateTask(context.Background(), NewTask{
+		UID:        "def",
+		Summary:    "Title",
+		Due:        time.Date(2024, time.June, 1, 9, 0, 0, 0, loc),
+		InstanceID: "def",
+		Timezone:   "Europe/Berlin",
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, body, "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin")
+	assert.Contains(t, body, "DUE;TZID=Europe/Berlin:20240601T090000")
+
+	cal, err := ical.NewDecoder(strings.NewReader(body)).Decode()
+	require.NoError(t, err)
+	todo := cal.Children[len(cal.Children)-1]
+	due, err := todo.Props.Get(ical.PropDue).DateTime(time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.June, 1, 7, 0, 0, 0, time.UTC), due.UTC())
+}
+
+func TestEmbedTimezoneSkipsUTC(t *testing.T) {
+	cal := existingTask().data
+
+	embedTimezone(cal, time.Date(2024, time.June, 1, 9, 0, 0, 0, time.UTC))
+
+	assert.Len(t, cal.Children, 1)
+}
+
+func TestFormatOffset(t *testing.T) {
+	assert.Equal(t, "+0530", formatOffset(5*3600+30*60))
+	assert.Equal(t, "-0300", formatOffset(-3*3600))
+	assert.Equal(t, "+001730", formatOffset(17*60+30))
+}
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/transport"
+)
+
+// CalendarsCommand lists the task lists available on CalDAV servers.
+type CalendarsCommand struct {
+	Config   string `name:"config" short:"c" help:"Path to configuration file whose servers are searched." default:"config.yaml" env:"TASKSEED_CONFIG"`
+	URL      string `name:"url" help:"Base CalDAV endpoint to search instead of the configured servers." env:"TASKSEED_CALDAV_URL"`
+	Username string `name:"username" help:"Login name for --url (default: environment variable or netrc)."`
+}
+
+// Run executes the calendars command.
+func (cmd *CalendarsCommand) Run() error {
+	servers, err := cmd.servers()
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "SERVER\tNAME\tURL")
+	for _, name := range servers.names {
+		lists, err := discoverTaskLists(name, servers.configs[name])
+		if err != nil {
+			return err
+		}
+		for _, list := range lists {
+			fmt.Fprintf(out, "%s\t%s\t%s\n", name, list.Name, list.URL)
+		}
+	}
+
+	return out.Flush()
+}
+
+type serverSet struct {
+	names   []string
+	configs map[string]config.ServerConfig
+}
+
+func (cmd *CalendarsCommand) servers() (serverSet, error) {
+	if cmd.URL == "" {
+		cfg, err := config.Load(cmd.Config)
+		if err != nil {
+			slog.Error("failed to load config", "error", err)
+			return serverSet{}, fmt.Errorf("load config: %w", err)
+		}
+		return serverSet{names: cfg.ServerNames(), configs: cfg.Servers}, nil
+	}
+
+	server, err := adhocServer(cmd.URL, cmd.Username)
+	if err != nil {
+		return serverSet{}, err
+	}
+	return serverSet{
+		names:   []string{config.DefaultServerName},
+		configs: map[string]config.ServerConfig{config.DefaultServerName: server},
+	}, nil
+}
+
+// adhocServer builds the default server from a URL given on the command line,
+// resolving credentials from the environment or netrc.
+func adhocServer(rawURL, username string) (config.ServerConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		slog.Error("failed to parse server url", "url", rawURL, "error", err)
+		return config.ServerConfig{}, fmt.Errorf("invalid server URL %q", rawURL)
+	}
+
+	server := config.ServerConfig{URL: u, Username: username}
+	if err := config.ResolveServerCredentials(context.Background(), config.DefaultServerName, &server, "."); err != nil {
+		return config.ServerConfig{}, err
+	}
+
+	return server, nil
+}
+
+// discoverTaskLists connects to a server and lists its collections supporting VTODO.
+func discoverTaskLists(name string, server config.ServerConfig) ([]caldav.TaskList, error) {
+	rt, err := transport.New(name, server)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := caldav.NewClient(server.URL.String(), rt)
+	if err != nil {
+		slog.Error("failed to create caldav client", "server", name, "error", err)
+		return nil, fmt.Errorf("create caldav client for server %q: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	lists, err := client.FindTaskLists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("discover task lists on server %q: %w", name, err)
+	}
+
+	slog.Debug("discovered task lists", "server", name, "count", len(lists))
+
+	return lists, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/eikendev/taskseed/internal/caldav"
 	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/transport"
 )
 
 // DoctorCommand validates configuration and basic connectivity.
@@ -23,18 +24,63 @@ func (cmd *DoctorCommand) Run() error {
 		return fmt.Errorf("load config: %w", err)
 	}
 
-	client, err := caldav.NewClient(cfg.Server.URL.String(), cfg.Target.URL.String(), cfg.Server.Username, cfg.Server.Password)
-	if err != nil {
-		slog.Error("failed to create caldav client", "error", err)
-		return fmt.Errorf("create caldav client: %w", err)
+	clients := make(map[string]*caldav.Client, len(cfg.Servers))
+	for _, name := range cfg.ServerNames() {
+		server := cfg.Servers[name]
+		rt, err := transport.New(name, server)
+		if err != nil {
+			return err
+		}
+		client, err := caldav.NewClient(server.URL.String(), rt)
+		if err != nil {
+			slog.Error("failed to create caldav client", "server", name, "error", err)
+			return fmt.Errorf("create caldav client for server %q: %w", name, err)
+		}
+		clients[name] = client
+		slog.Info("resolved server credentials", "server", name, "auth", server.Auth.Type, "username", server.Username, "source", server.CredentialSource)
+	}
+
+	for _, name := range cfg.TargetNames() {
+		target := cfg.Targets[name]
+		client, err := clients[target.Server].WithCalendar(target.URL.String())
+		if err != nil {
+			slog.Error("failed to create caldav client", "target", name, "error", err)
+			return fmt.Errorf("create caldav client for target %q: %w", name, err)
+		}
+
+		if err := checkTarget(client); err != nil {
+			return fmt.Errorf("caldav doctor failed for target %q: %w", name, err)
+		}
+
+		slog.Info("checked target", "target", name, "server", target.Server, "rules", len(cfg.RulesFor(name)))
 	}
 
+	return nil
+}
+
+// checkTarget probes a target and reports certificate and authentication
+// problems distinctly from other connectivity failures.
+func checkTarget(client *caldav.Client) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	_, err = client.QueryTasks(ctx, time.Now().Add(-24*time.Hour), time.Now().Add(24*time.Hour))
-	if err != nil {
+
+	err := client.Probe(ctx)
+	switch {
+	case err == nil:
+	case transport.IsCertificateError(err):
+		slog.Error("failed to verify server certificate", "error", err)
+		return fmt.Errorf("certificate problem: %w", err)
+	case caldav.IsUnauthorized(err):
+		slog.Error("failed to authenticate", "error", err)
+		return fmt.Errorf("authentication failed: %w", err)
+	default:
 		slog.Error("failed to check connectivity", "error", err)
-		return fmt.Errorf("caldav doctor failed: %w", err)
+		return fmt.Errorf("connectivity check failed: %w", err)
+	}
+
+	if _, err := client.QueryTasks(ctx, time.Now().Add(-24*time.Hour), time.Now().Add(24*time.Hour)); err != nil {
+		slog.Error("failed to query tasks", "error", err)
+		return fmt.Errorf("query tasks: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+// InitCommand writes a starter configuration for a discovered task list.
+type InitCommand struct {
+	Config   string `name:"config" short:"c" help:"Path of the configuration file to write." default:"config.yaml" env:"TASKSEED_CONFIG"`
+	URL      string `name:"url" help:"Base CalDAV endpoint." required:"" env:"TASKSEED_CALDAV_URL"`
+	Username string `name:"username" help:"Login name (default: environment variable or netrc)."`
+	Calendar string `name:"calendar" help:"Name, path, or URL of the task list to use instead of asking."`
+	Force    bool   `name:"force" help:"Overwrite an existing configuration file."`
+}
+
+// starterConfig is the configuration written by the init command.
+var starterConfig = template.Must(template.New("config").Parse(`# Generated by taskseed init; see the README for all options.
+server:
+  url: {{ printf "%q" .ServerURL }}
+{{- if .Username }}
+  username: {{ printf "%q" .Username }}
+{{- end }}
+
+target:
+  # {{ .ListName }}
+  url: {{ printf "%q" .ListURL }}
+
+sync:
+  horizonDays: 30
+  lookbackDays: 7
+
+defaults:
+  timezone: UTC
+  due:
+    time: "09:00"
+
+rules:
+  - id: water_plants
+    title: Water the houseplants
+    schedule:
+      kind: weekly
+      weekdays: [monday, thursday]
+
+  - id: change_sheets
+    title: Change bedsheets
+    schedule:
+      kind: monthly_day
+      monthDays: [1]
+`))
+
+type starterValues struct {
+	ServerURL string
+	Username  string
+	ListName  string
+	ListURL   string
+}
+
+// Run executes the init command.
+func (cmd *InitCommand) Run() error {
+	if !cmd.Force {
+		if _, err := os.Stat(cmd.Config); err == nil {
+			slog.Error("refusing to overwrite config", "path", cmd.Config)
+			return fmt.Errorf("config file %q already exists; use --force to overwrite it", cmd.Config)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("check config file %q: %w", cmd.Config, err)
+		}
+	}
+
+	server, err := adhocServer(cmd.URL, cmd.Username)
+	if err != nil {
+		return err
+	}
+
+	lists, err := discoverTaskLists(config.DefaultServerName, server)
+	if err != nil {
+		return err
+	}
+
+	list, err := chooseTaskList(lists, cmd.Calendar, os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := starterConfig.Execute(&buf, starterValues{
+		ServerURL: server.URL.String(),
+		Username:  cmd.Username,
+		ListName:  displayName(list),
+		ListURL:   list.URL,
+	}); err != nil {
+		return fmt.Errorf("render config: %w", err)
+	}
+
+	if err := os.WriteFile(cmd.Config, buf.Bytes(), 0o600); err != nil {
+		slog.Error("failed to write config", "path", cmd.Config, "error", err)
+		return fmt.Errorf("write config file %q: %w", cmd.Config, err)
+	}
+
+	fmt.Printf("Wrote %s for task list %q.\n", cmd.Config, displayName(list))
+
+	return nil
+}
+
+// chooseTaskList selects the list matching want by name, path, or URL. Without
+// want, a single list is taken as is and otherwise the user is asked.
+func chooseTaskList(lists []caldav.TaskList, want string, in io.Reader, out io.Writer) (caldav.TaskList, error) {
+	if len(lists) == 0 {
+		return caldav.TaskList{}, errors.New("no task lists found on the server")
+	}
+
+	if want != "" {
+		for _, list := range lists {
+			if strings.EqualFold(list.Name, want) || list.Path == want || list.URL == want {
+				return list, nil
+			}
+		}
+		return caldav.TaskList{}, fmt.Errorf("no task list matches %q", want)
+	}
+
+	if len(lists) == 1 {
+		return lists[0], nil
+	}
+
+	for i, list := range lists {
+		fmt.Fprintf(out, "%d) %s\t%s\n", i+1, displayName(list), list.URL)
+	}
+	fmt.Fprintf(out, "Select a task list [1-%d]: ", len(lists))
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return caldav.TaskList{}, fmt.Errorf("read selection: %w", err)
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(lists) {
+		return caldav.TaskList{}, fmt.Errorf("invalid selection %q", strings.TrimSpace(line))
+	}
+
+	return lists[choice-1], nil
+}
+
+func displayName(list caldav.TaskList) string {
+	if list.Name != "" {
+		return list.Name
+	}
+	return list.Path
+}
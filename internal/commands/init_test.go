@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+func TestStarterConfigLoads(t *testing.T) {
+	t.Setenv("TASKSEED_CALDAV_USERNAME", "alice")
+	t.Setenv("TASKSEED_CALDAV_PASSWORD", "s3cret")
+
+	var buf bytes.Buffer
+	require.NoError(t, starterConfig.Execute(&buf, starterValues{
+		ServerURL: "https://cal.example.com/dav/",
+		Username:  "alice",
+		ListName:  "Chores",
+		ListURL:   "https://cal.example.com/dav/calendars/alice/chores/",
+	}))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	cfg, err := config.Load(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://cal.example.com/dav/calendars/alice/chores/", cfg.Targets[config.DefaultTargetName].URL.String())
+	assert.Equal(t, "alice", cfg.Servers[config.DefaultServerName].Username)
+	assert.Len(t, cfg.Rules, 2)
+}
+
+func TestChooseTaskList(t *testing.T) {
+	lists := []caldav.TaskList{
+		{Name: "Chores", Path: "/dav/calendars/alice/chores/"},
+		{Name: "Work", Path: "/dav/calendars/alice/work/"},
+	}
+
+	list, err := chooseTaskList(lists, "work", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Work", list.Name)
+
+	var out bytes.Buffer
+	list, err = chooseTaskList(lists, "", strings.NewReader("1\n"), &out)
+	require.NoError(t, err)
+	assert.Equal(t, "Chores", list.Name)
+	assert.Contains(t, out.String(), "2) Work")
+
+	_, err = chooseTaskList(lists, "", strings.NewReader("3\n"), &out)
+	assert.Error(t, err)
+}
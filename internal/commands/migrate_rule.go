@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/runner"
+)
+
+// MigrateRuleCommand rewrites the rule ID stored on existing tasks.
+type MigrateRuleCommand struct {
+	Config string `name:"config" short:"c" help:"Path to configuration file." default:"config.yaml" env:"TASKSEED_CONFIG"`
+	DryRun bool   `name:"dry-run" help:"Print planned changes without changing tasks." env:"TASKSEED_DRY_RUN"`
+	From   string `name:"from" help:"Rule ID currently stored on the tasks." required:""`
+	To     string `name:"to" help:"Rule ID in the configuration to move the tasks to." required:""`
+}
+
+// Run executes the migrate-rule command.
+func (cmd *MigrateRuleCommand) Run() error {
+	start := time.Now()
+	slog.Info("starting rule migration", "config", cmd.Config, "from", cmd.From, "to", cmd.To, "dry_run", cmd.DryRun)
+
+	cfg, err := config.Load(cmd.Config)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := runner.MigrateRule(ctx, cfg, cmd.From, cmd.To, runner.Options{
+		DryRun: cmd.DryRun,
+	}); err != nil {
+		slog.Error("failed to migrate rule", "error", err)
+		return fmt.Errorf("migrate rule failed: %w", err)
+	}
+
+	slog.Info("completed rule migration", "duration", time.Since(start).String(), "dry_run", cmd.DryRun)
+
+	return nil
+}
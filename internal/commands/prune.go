@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/runner"
+)
+
+// PruneCommand removes tasks of rules that no longer exist in the configuration.
+type PruneCommand struct {
+	Config string `name:"config" short:"c" help:"Path to configuration file." default:"config.yaml" env:"TASKSEED_CONFIG"`
+	DryRun bool   `name:"dry-run" help:"Print planned removals without changing tasks." env:"TASKSEED_DRY_RUN"`
+}
+
+// Run executes the prune command.
+func (cmd *PruneCommand) Run() error {
+	start := time.Now()
+	slog.Info("starting prune", "config", cmd.Config, "dry_run", cmd.DryRun)
+
+	cfg, err := config.Load(cmd.Config)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	slog.Debug("loaded config", "rules", len(cfg.Rules), "prune_action", cfg.Sync.PruneAction, "prune_limit", *cfg.Sync.PruneLimit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := runner.Prune(ctx, cfg, runner.Options{
+		DryRun: cmd.DryRun,
+	}); err != nil {
+		slog.Error("failed to prune", "error", err)
+		return fmt.Errorf("prune failed: %w", err)
+	}
+
+	slog.Info("completed prune", "duration", time.Since(start).String(), "dry_run", cmd.DryRun)
+
+	return nil
+}
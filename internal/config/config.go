@@ -2,82 +2,190 @@
 package config
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/goccy/go-yaml"
-)
 
-// #nosec G101 -- These are environment variable names, not credentials
-const (
-	envUsernameVar = "TASKSEED_CALDAV_USERNAME"
-	envPasswordVar = "TASKSEED_CALDAV_PASSWORD"
+	"github.com/eikendev/taskseed/internal/holidays"
 )
 
+// DefaultServerName names the server configured through the single-server `server` key.
+const DefaultServerName = "default"
+
+// DefaultTargetName names the task list configured through the single-target `target` key.
+const DefaultTargetName = "default"
+
+// defaultPruneLimit caps how many tasks a single run removes unless configured otherwise.
+const defaultPruneLimit = 10
+
 // Config mirrors the user-provided YAML structure.
 type Config struct {
-	Server   ServerConfig   `yaml:"server" validate:"required"`
-	Target   TargetConfig   `yaml:"target" validate:"required"`
-	Sync     SyncConfig     `yaml:"sync"`
-	Defaults DefaultsConfig `yaml:"defaults"`
-	Rules    []Rule         `yaml:"rules" validate:"dive"`
+	Server   *ServerConfig           `yaml:"server" validate:"required_without=Servers,excluded_with=Servers"`
+	Servers  map[string]ServerConfig `yaml:"servers" validate:"required_without=Server,dive,keys,required,endkeys"`
+	Target   *TargetConfig           `yaml:"target" validate:"required_without=Targets,excluded_with=Targets"`
+	Targets  map[string]TargetConfig `yaml:"targets" validate:"required_without=Target,dive,keys,required,endkeys"`
+	Sync     SyncConfig              `yaml:"sync"`
+	Defaults DefaultsConfig          `yaml:"defaults"`
+	Rules    []Rule                  `yaml:"rules" validate:"dive"`
 }
 
 // ServerConfig holds CalDAV server connection references.
 type ServerConfig struct {
-	URL      *url.URL `yaml:"url" validate:"required"`
-	Username string   `yaml:"username"`
-	Password string   `yaml:"password"` // #nosec G117 -- configuration field, not a hardcoded secret
+	URL              *url.URL         `yaml:"url" validate:"required"`
+	Username         string           `yaml:"username"`
+	Password         string           `yaml:"password" validate:"excluded_with=PasswordFile PasswordCommand"` // #nosec G117 -- configuration field, not a hardcoded secret
+	PasswordFile     string           `yaml:"passwordFile" validate:"excluded_with=Password PasswordCommand"`
+	PasswordCommand  string           `yaml:"passwordCommand" validate:"excluded_with=Password PasswordFile"`
+	Auth             AuthConfig       `yaml:"auth"`
+	TLS              TLSConfig        `yaml:"tls"`
+	Quirks           []Quirk          `yaml:"quirks" validate:"dive,validateFn=IsAQuirk"`
+	CredentialSource CredentialSource `yaml:"-"`
+}
+
+// HasQuirk reports whether the server is configured with the given quirk.
+func (s ServerConfig) HasQuirk(quirk Quirk) bool {
+	return slices.Contains(s.Quirks, quirk)
+}
+
+// AuthConfig selects how requests to a server are authenticated. The password
+// sources of the server provide the bearer token or the OAuth2 refresh token.
+type AuthConfig struct {
+	Type         AuthType `yaml:"type" validate:"validateFn=IsAAuthType"`
+	TokenURL     *url.URL `yaml:"tokenURL"`
+	ClientID     string   `yaml:"clientID"`
+	ClientSecret string   `yaml:"clientSecret"` // #nosec G117 -- configuration field, not a hardcoded secret
+	Scopes       []string `yaml:"scopes"`
+	CacheFile    string   `yaml:"cacheFile"`
 }
 
-// TargetConfig identifies the calendar to operate on.
+// TargetConfig identifies a calendar to operate on.
 type TargetConfig struct {
-	URL *url.URL `yaml:"url" validate:"required"`
+	URL         *url.URL `yaml:"url" validate:"required"`
+	Server      string   `yaml:"server"`
+	Namespace   string   `yaml:"namespace"`
+	Create      bool     `yaml:"create"`
+	DisplayName string   `yaml:"displayName"`
+	Color       string   `yaml:"color" validate:"omitempty,hexcolor"`
 }
 
-// SyncConfig defines horizon and lookback settings.
+// TLSConfig customizes certificate verification and client certificates for a server.
+type TLSConfig struct {
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile" validate:"required_with=KeyFile"`
+	KeyFile            string `yaml:"keyFile" validate:"required_with=CertFile"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// SyncConfig defines horizon, lookback, and pruning settings.
 type SyncConfig struct {
-	HorizonDays  int `yaml:"horizonDays" validate:"gt=0"`
-	LookbackDays int `yaml:"lookbackDays" validate:"gt=0"`
+	HorizonDays  int         `yaml:"horizonDays" validate:"gt=0"`
+	LookbackDays int         `yaml:"lookbackDays" validate:"gt=0"`
+	Prune        bool        `yaml:"prune"`
+	PruneAction  PruneAction `yaml:"pruneAction" validate:"validateFn=IsAPruneAction"`
+	PruneLimit   *int        `yaml:"pruneLimit" validate:"omitnil,gt=0"`
+	StateFile    string      `yaml:"stateFile"`
 }
 
 // DefaultsConfig configures rule defaults.
 type DefaultsConfig struct {
-	Timezone *time.Location `yaml:"timezone"`
-	Due      DuePreference  `yaml:"due"`
+	Timezone     *time.Location    `yaml:"timezone"`
+	Due          DuePreference     `yaml:"due"`
+	Holidays     string            `yaml:"holidays"`
+	HolidayDates []Date            `yaml:"-"`
+	Materialize  MaterializeConfig `yaml:"materialize"`
 }
 
-// DuePreference describes default due-time behavior.
+// DuePreference describes default due-time behavior. DateOnly is a shorthand
+// for Mode date kept for existing configurations.
 type DuePreference struct {
 	Time     ClockTime `yaml:"time"`
+	Mode     DueMode   `yaml:"mode" validate:"validateFn=IsADueMode"`
 	DateOnly bool      `yaml:"dateOnly"`
 }
 
-// Rule defines a recurrence rule.
+// MaterializeConfig controls how many instances of a rule are created ahead of time.
+type MaterializeConfig struct {
+	Mode  MaterializeMode `yaml:"mode" validate:"validateFn=IsAMaterializeMode"`
+	Count int             `yaml:"count" validate:"gte=0"`
+}
+
+// Rule defines a recurrence rule. EffectiveDue holds Due applied on top of
+// defaults.due once the configuration is loaded.
 type Rule struct {
-	ID       string       `yaml:"id" validate:"required"`
-	Title    string       `yaml:"title" validate:"required"`
-	Notes    string       `yaml:"notes"`
-	Schedule RuleSchedule `yaml:"schedule" validate:"required"`
+	ID           string             `yaml:"id" validate:"required"`
+	Aliases      []string           `yaml:"aliases" validate:"dive,required"`
+	Title        string             `yaml:"title" validate:"required"`
+	Target       string             `yaml:"target"`
+	Notes        string             `yaml:"notes"`
+	Schedule     RuleSchedule       `yaml:"schedule" validate:"required"`
+	StartDate    *Date              `yaml:"startDate"`
+	EndDate      *Date              `yaml:"endDate"`
+	Count        int                `yaml:"count" validate:"gte=0"`
+	Materialize  *MaterializeConfig `yaml:"materialize"`
+	Timezone     *time.Location     `yaml:"timezone"`
+	Due          DueOverride        `yaml:"due"`
+	EffectiveDue DuePreference      `yaml:"-"`
+}
+
+// DueOverride replaces individual fields of defaults.due for a single rule.
+type DueOverride struct {
+	Time     *ClockTime `yaml:"time"`
+	Mode     *DueMode   `yaml:"mode" validate:"omitempty,validateFn=IsADueMode"`
+	DateOnly *bool      `yaml:"dateOnly"`
+}
+
+// apply returns due with the fields set in the override replaced.
+func (o DueOverride) apply(due DuePreference) DuePreference {
+	if o.Time != nil {
+		due.Time = *o.Time
+	}
+	if o.Mode != nil {
+		due.Mode = *o.Mode
+	}
+	if o.DateOnly != nil {
+		due.DateOnly = *o.DateOnly
+		switch {
+		case due.DateOnly:
+			due.Mode = DueModeDate
+		case o.Mode == nil && due.Mode == DueModeDate:
+			due.Mode = DueModeZoned
+		}
+	}
+	return due
 }
 
 // RuleSchedule holds recurrence parameters.
 type RuleSchedule struct {
-	Kind             ScheduleKind   `yaml:"kind" validate:"validateFn=IsAScheduleKind"` // technically required through validateFn
-	Weekdays         []time.Weekday `yaml:"weekdays" validate:"dive"`
-	EveryNDays       int            `yaml:"everyNDays" validate:"gte=0"`
-	MonthDays        []int          `yaml:"monthDays" validate:"dive,gte=1,lte=31"`
-	Month            int            `yaml:"month" validate:"gte=0,lte=12"`
-	Day              int            `yaml:"day" validate:"gte=0,lte=31"`
-	Nth              int            `yaml:"nth" validate:"gte=0"`
-	NthWeekday       time.Weekday   `yaml:"nthWeekday"`
-	YearlyNthWeekday time.Weekday   `yaml:"yearlyNthWeekday"`
+	Kind                ScheduleKind    `yaml:"kind" validate:"validateFn=IsAScheduleKind"` // technically required through validateFn
+	Weekdays            []time.Weekday  `yaml:"weekdays" validate:"dive"`
+	EveryNDays          int             `yaml:"everyNDays" validate:"gte=0"`
+	MonthDays           []int           `yaml:"monthDays" validate:"dive,gte=-31,lte=31,ne=0"`
+	Month               int             `yaml:"month" validate:"gte=0,lte=12"`
+	Day                 int             `yaml:"day" validate:"gte=0,lte=31"`
+	Nth                 int             `yaml:"nth" validate:"gte=-23,lte=23"`
+	NthWeekday          time.Weekday    `yaml:"nthWeekday"`
+	YearlyNthWeekday    time.Weekday    `yaml:"yearlyNthWeekday"`
+	RRule               string          `yaml:"rrule"`
+	ClampToMonthEnd     bool            `yaml:"clampToMonthEnd"`
+	Interval            int             `yaml:"interval" validate:"gte=0"`
+	IntervalAnchor      *Date           `yaml:"intervalAnchor"`
+	ExceptDates         []Date          `yaml:"exceptDates"`
+	OnExcluded          ExclusionPolicy `yaml:"onExcluded" validate:"validateFn=IsAExclusionPolicy"`
+	Shift               ShiftMode       `yaml:"shift" validate:"validateFn=IsAShiftMode"`
+	DaysAfterCompletion int             `yaml:"daysAfterCompletion" validate:"gte=0"`
 }
 
 // ClockTime represents an hour and minute.
@@ -86,6 +194,18 @@ type ClockTime struct {
 	Minute int
 }
 
+// Date represents a calendar date without a time of day.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// In returns midnight of the date in the given location.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
 func validateConfig(cfg Config) error {
 	v := newValidator()
 	if err := v.Struct(cfg); err != nil {
@@ -99,6 +219,11 @@ func newValidator() *validator.Validate {
 	if err := validate.RegisterValidation("validateFn", validateFn); err != nil {
 		panic(err)
 	}
+	validate.RegisterStructValidation(validateRule, Rule{})
+	validate.RegisterStructValidation(validateMaterialize, MaterializeConfig{})
+	validate.RegisterStructValidation(validateAuth, AuthConfig{})
+	validate.RegisterStructValidation(validateDue, DuePreference{})
+	validate.RegisterStructValidation(validateDueOverride, DueOverride{})
 	validate.RegisterStructValidation(validateRuleSchedule, RuleSchedule{})
 	return validate
 }
@@ -108,6 +233,34 @@ var enumValidation = map[string]func(any) bool{
 		kind, ok := value.(ScheduleKind)
 		return ok && kind.IsAScheduleKind()
 	},
+	"IsAExclusionPolicy": func(value any) bool {
+		policy, ok := value.(ExclusionPolicy)
+		return ok && policy.IsAExclusionPolicy()
+	},
+	"IsAShiftMode": func(value any) bool {
+		mode, ok := value.(ShiftMode)
+		return ok && mode.IsAShiftMode()
+	},
+	"IsAMaterializeMode": func(value any) bool {
+		mode, ok := value.(MaterializeMode)
+		return ok && mode.IsAMaterializeMode()
+	},
+	"IsAPruneAction": func(value any) bool {
+		action, ok := value.(PruneAction)
+		return ok && action.IsAPruneAction()
+	},
+	"IsAAuthType": func(value any) bool {
+		authType, ok := value.(AuthType)
+		return ok && authType.IsAAuthType()
+	},
+	"IsADueMode": func(value any) bool {
+		mode, ok := value.(DueMode)
+		return ok && mode.IsADueMode()
+	},
+	"IsAQuirk": func(value any) bool {
+		quirk, ok := value.(Quirk)
+		return ok && quirk.IsAQuirk()
+	},
 }
 
 func validateFn(fl validator.FieldLevel) bool {
@@ -131,18 +284,57 @@ func validateFn(fl validator.FieldLevel) bool {
 }
 
 func finalizeConfig(cfg *Config) error {
-	username := os.Getenv(envUsernameVar)
-	password := os.Getenv(envPasswordVar)
-	if username == "" || password == "" {
-		slog.Error("missing server credentials")
-		return errors.New("missing server credentials in environment")
+	if cfg.Server != nil {
+		cfg.Servers = map[string]ServerConfig{DefaultServerName: *cfg.Server}
+		cfg.Server = nil
+	}
+	envNames := make(map[string]string)
+	for _, name := range cfg.ServerNames() {
+		envVar, _ := credentialEnvVars(name)
+		if other, exists := envNames[envVar]; exists {
+			slog.Error("detected colliding server names", "server", name, "other", other, "env", envVar)
+			return fmt.Errorf("servers[%s] shares its credential environment variables with servers[%s]", name, other)
+		}
+		envNames[envVar] = name
 	}
-	cfg.Server.Username = username
-	cfg.Server.Password = password
 
+	if cfg.Target != nil {
+		cfg.Targets = map[string]TargetConfig{DefaultTargetName: *cfg.Target}
+		cfg.Target = nil
+	}
+	namespaces := make(map[[2]string]string)
+	for _, name := range cfg.TargetNames() {
+		target := cfg.Targets[name]
+		if target.Server == "" {
+			target.Server = defaultName(cfg.Servers, DefaultServerName)
+		}
+		if _, ok := cfg.Servers[target.Server]; !ok {
+			slog.Error("detected unknown target server", "target", name, "server", target.Server)
+			return fmt.Errorf("targets[%s].server must reference a configured server", name)
+		}
+		if target.Namespace == "" {
+			target.Namespace = defaultNamespace(name, target.URL)
+		}
+		key := [2]string{target.Server, target.Namespace}
+		if other, exists := namespaces[key]; exists {
+			slog.Error("detected duplicate target namespace", "target", name, "other", other, "namespace", target.Namespace)
+			return fmt.Errorf("targets[%s].namespace must differ from targets[%s] on the same server", name, other)
+		}
+		namespaces[key] = name
+		if target.DisplayName == "" {
+			target.DisplayName = name
+		}
+		cfg.Targets[name] = target
+	}
 	if cfg.Defaults.Timezone == nil {
 		cfg.Defaults.Timezone = time.UTC
 	}
+	if cfg.Defaults.Due.DateOnly {
+		cfg.Defaults.Due.Mode = DueModeDate
+	}
+	if cfg.Sync.PruneLimit == nil {
+		cfg.Sync.PruneLimit = new(defaultPruneLimit)
+	}
 
 	seen := make(map[string]struct{})
 	for i := range cfg.Rules {
@@ -154,9 +346,93 @@ func finalizeConfig(cfg *Config) error {
 		seen[rule.ID] = struct{}{}
 	}
 
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		for _, alias := range rule.Aliases {
+			if _, exists := seen[alias]; exists {
+				slog.Error("detected duplicate rule alias", "index", i, "id", rule.ID, "alias", alias)
+				return fmt.Errorf("rules[%d].aliases must not reuse rule id or alias %q", i, alias)
+			}
+			seen[alias] = struct{}{}
+		}
+
+		if rule.Target == "" {
+			rule.Target = defaultName(cfg.Targets, DefaultTargetName)
+		}
+		if _, ok := cfg.Targets[rule.Target]; !ok {
+			slog.Error("detected unknown rule target", "index", i, "id", rule.ID, "target", rule.Target)
+			return fmt.Errorf("rules[%d].target must reference a configured target", i)
+		}
+
+		if rule.Schedule.IntervalAnchor == nil {
+			rule.Schedule.IntervalAnchor = rule.StartDate
+		}
+		if rule.Materialize == nil {
+			rule.Materialize = new(cfg.Defaults.Materialize)
+		}
+		if rule.Timezone == nil {
+			rule.Timezone = cfg.Defaults.Timezone
+		}
+		rule.EffectiveDue = rule.Due.apply(cfg.Defaults.Due)
+	}
+
 	return nil
 }
 
+// defaultName returns the entry used when a reference is left empty: the only
+// configured entry, or the one called fallback.
+// defaultNamespace returns the collection name of a task list, which stays the
+// same when the list is reached through another host, scheme, or proxy path.
+// Lists without one fall back to the target name.
+func defaultNamespace(name string, u *url.URL) string {
+	if collection := path.Base(strings.TrimSuffix(u.Path, "/")); collection != "." && collection != "/" {
+		return collection
+	}
+	return name
+}
+
+func defaultName[T any](entries map[string]T, fallback string) string {
+	if len(entries) == 1 {
+		for name := range entries {
+			return name
+		}
+	}
+	return fallback
+}
+
+// ServerNames returns the names of all configured servers in sorted order.
+func (c Config) ServerNames() []string {
+	return slices.Sorted(maps.Keys(c.Servers))
+}
+
+// TargetNames returns the names of all configured targets in sorted order.
+func (c Config) TargetNames() []string {
+	return slices.Sorted(maps.Keys(c.Targets))
+}
+
+// RulesFor returns the rules that belong to the named target.
+func (c Config) RulesFor(target string) []Rule {
+	var rules []Rule
+	for _, rule := range c.Rules {
+		if rule.Target == target {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// CanonicalRuleIDs maps every rule ID and alias to the ID of the rule it belongs to.
+func CanonicalRuleIDs(rules []Rule) map[string]string {
+	ids := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		ids[rule.ID] = rule.ID
+		for _, alias := range rule.Aliases {
+			ids[alias] = rule.ID
+		}
+	}
+	return ids
+}
+
 // Load reads and validates configuration from disk.
 func Load(path string) (Config, error) {
 	RegisterParsers()
@@ -166,28 +442,17 @@ func Load(path string) (Config, error) {
 		slog.Error("failed to resolve config path", "error", err)
 		return Config{}, fmt.Errorf("resolve config path %q: %w", path, err)
 	}
-	rootDir := filepath.Dir(absPath)
-	fileName := filepath.Base(absPath)
 
-	root, err := os.OpenRoot(rootDir)
-	if err != nil {
-		slog.Error("failed to open config root", "error", err)
-		return Config{}, fmt.Errorf("open config root %q: %w", rootDir, err)
-	}
-	defer func() {
-		_ = root.Close()
-	}()
-
-	raw, err := root.ReadFile(fileName)
+	raw, err := readFile(absPath)
 	if err != nil {
 		slog.Error("failed to read config file", "error", err)
-		return Config{}, fmt.Errorf("read config file %q: %w", fileName, err)
+		return Config{}, fmt.Errorf("read config file %q: %w", filepath.Base(absPath), err)
 	}
 
 	var cfg Config
 	if err := yaml.Unmarshal(raw, &cfg); err != nil {
 		slog.Error("failed to parse config", "error", err)
-		return Config{}, fmt.Errorf("parse config %q: %w", fileName, err)
+		return Config{}, fmt.Errorf("parse config %q: %w", filepath.Base(absPath), err)
 	}
 
 	if err := validateConfig(cfg); err != nil {
@@ -198,5 +463,80 @@ func Load(path string) (Config, error) {
 		return Config{}, err
 	}
 
+	resolvePaths(&cfg, filepath.Dir(absPath))
+
+	if err := resolveCredentials(context.Background(), &cfg, filepath.Dir(absPath)); err != nil {
+		return Config{}, err
+	}
+
+	if err := loadHolidays(&cfg, filepath.Dir(absPath)); err != nil {
+		return Config{}, err
+	}
+
 	return cfg, nil
 }
+
+// resolvePaths makes the server file paths and the sync state file absolute,
+// resolving relative paths against the configuration directory.
+func resolvePaths(cfg *Config, configDir string) {
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(configDir, *path)
+		}
+	}
+
+	for name, server := range cfg.Servers {
+		resolve(&server.PasswordFile)
+		resolve(&server.Auth.CacheFile)
+		resolve(&server.TLS.CAFile)
+		resolve(&server.TLS.CertFile)
+		resolve(&server.TLS.KeyFile)
+		cfg.Servers[name] = server
+	}
+
+	resolve(&cfg.Sync.StateFile)
+}
+
+func loadHolidays(cfg *Config, configDir string) error {
+	if cfg.Defaults.Holidays == "" {
+		return nil
+	}
+
+	path := cfg.Defaults.Holidays
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+
+	raw, err := readFile(path)
+	if err != nil {
+		slog.Error("failed to read holiday calendar", "path", path, "error", err)
+		return fmt.Errorf("read holiday calendar %q: %w", path, err)
+	}
+
+	// Recurring holidays are expanded one year past the horizon to cover shifted occurrences.
+	until := time.Now().AddDate(1, 0, cfg.Sync.HorizonDays)
+	dates, err := holidays.Parse(bytes.NewReader(raw), until)
+	if err != nil {
+		return fmt.Errorf("parse holiday calendar %q: %w", path, err)
+	}
+
+	for _, d := range dates {
+		cfg.Defaults.HolidayDates = append(cfg.Defaults.HolidayDates, Date{Year: d.Year(), Month: d.Month(), Day: d.Day()})
+	}
+	slog.Debug("loaded holiday calendar", "path", path, "dates", len(cfg.Defaults.HolidayDates))
+
+	return nil
+}
+
+// readFile reads a file through a root scoped to its directory.
+func readFile(path string) ([]byte, error) {
+	root, err := os.OpenRoot(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("open root: %w", err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	return root.ReadFile(filepath.Base(path))
+}
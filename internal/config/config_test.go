@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDueOverrideReplacesOnlySetFields(t *testing.T) {
+	defaults := DuePreference{Time: ClockTime{Hour: 9}, Mode: DueModeFloating}
+
+	assert.Equal(t, defaults, DueOverride{}.apply(defaults))
+	assert.Equal(t,
+		DuePreference{Time: ClockTime{Hour: 19}, Mode: DueModeFloating},
+		DueOverride{Time: &ClockTime{Hour: 19}}.apply(defaults))
+	assert.Equal(t,
+		DuePreference{Time: ClockTime{Hour: 9}, Mode: DueModeDate, DateOnly: true},
+		DueOverride{DateOnly: new(true)}.apply(defaults))
+	assert.Equal(t,
+		DuePreference{Time: ClockTime{Hour: 7, Minute: 30}, Mode: DueModeZoned},
+		DueOverride{Time: &ClockTime{Hour: 7, Minute: 30}, DateOnly: new(false)}.apply(DuePreference{Mode: DueModeDate, DateOnly: true}))
+}
+
+const baseConfig = `
+server:
+  url: https://cal.example.com/dav/
+  username: alice
+  password: s3cret
+target:
+  url: https://cal.example.com/dav/calendars/alice/chores/
+sync:
+  horizonDays: 30
+  lookbackDays: 7
+rules:
+  - id: water_plants
+    title: Water plants
+    schedule:
+      kind: weekly
+      weekdays: [monday]
+`
+
+// loadConfig writes raw to a config file and loads it.
+func loadConfig(t *testing.T, raw string) (Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(raw), 0o600))
+
+	return Load(path)
+}
+
+func TestPruneLimitDefaultsOnlyWhenUnset(t *testing.T) {
+	cfg, err := loadConfig(t, baseConfig)
+	require.NoError(t, err)
+	assert.Equal(t, defaultPruneLimit, *cfg.Sync.PruneLimit)
+
+	cfg, err = loadConfig(t, strings.Replace(baseConfig, "sync:\n", "sync:\n  pruneLimit: 3\n", 1))
+	require.NoError(t, err)
+	assert.Equal(t, 3, *cfg.Sync.PruneLimit)
+
+	_, err = loadConfig(t, strings.Replace(baseConfig, "sync:\n", "sync:\n  pruneLimit: 0\n", 1))
+	assert.Error(t, err)
+}
+
+func TestDefaultNamespaceDoesNotDependOnURL(t *testing.T) {
+	before, err := loadConfig(t, baseConfig)
+	require.NoError(t, err)
+	after, err := loadConfig(t, strings.Replace(baseConfig,
+		"https://cal.example.com/dav/calendars/alice/chores/", "https://proxy.example.com/caldav/alice/chores/", 1))
+	require.NoError(t, err)
+
+	assert.Equal(t, "chores", before.Targets[DefaultTargetName].Namespace)
+	assert.Equal(t, before.Targets[DefaultTargetName].Namespace, after.Targets[DefaultTargetName].Namespace)
+}
+
+func TestServerNamesMustNotShareCredentialEnvVars(t *testing.T) {
+	servers := `
+servers:
+  next-cloud:
+    url: https://cloud.example.com/remote.php/dav
+    username: alice
+    password: s3cret
+  next_cloud:
+    url: https://cloud.example.org/remote.php/dav
+    username: alice
+    password: s3cret
+target:
+  url: https://cloud.example.com/remote.php/dav/calendars/alice/chores/
+  server: next-cloud
+`
+	raw := servers + baseConfig[strings.Index(baseConfig, "sync:"):]
+
+	_, err := loadConfig(t, raw)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "servers[next_cloud]")
+
+	_, err = loadConfig(t, strings.Replace(raw, "next_cloud:", "nextcloud:", 1))
+	assert.NoError(t, err)
+}
+
+func TestTargetsOnOneServerNeedDistinctNamespaces(t *testing.T) {
+	targets := `
+targets:
+  alice:
+    url: https://cal.example.com/dav/calendars/alice/tasks/
+  bob:
+    url: https://cal.example.com/dav/calendars/bob/tasks/
+`
+	raw := strings.Replace(baseConfig, "target:\n  url: https://cal.example.com/dav/calendars/alice/chores/\n", targets, 1)
+	raw = strings.Replace(raw, "    title: Water plants\n", "    title: Water plants\n    target: alice\n", 1)
+
+	_, err := loadConfig(t, raw)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "targets[bob].namespace")
+
+	cfg, err := loadConfig(t, strings.Replace(raw, "bob/tasks/\n", "bob/tasks/\n    namespace: bob-tasks\n", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "tasks", cfg.Targets["alice"].Namespace)
+	assert.Equal(t, "bob-tasks", cfg.Targets["bob"].Namespace)
+}
+
+func TestRRuleIntervalNeedsStart(t *testing.T) {
+	rrule := func(value string) string {
+		return strings.Replace(baseConfig, "      kind: weekly\n      weekdays: [monday]\n", "      kind: rrule\n      rrule: "+value+"\n", 1)
+	}
+
+	_, err := loadConfig(t, rrule("FREQ=WEEKLY"))
+	require.NoError(t, err)
+	_, err = loadConfig(t, rrule("FREQ=WEEKLY;INTERVAL=2"))
+	assert.Error(t, err)
+	_, err = loadConfig(t, rrule("DTSTART=20260105T000000Z;FREQ=WEEKLY;INTERVAL=2"))
+	require.NoError(t, err)
+	_, err = loadConfig(t, strings.Replace(rrule("FREQ=WEEKLY;INTERVAL=2"), "    schedule:\n", "    startDate: 2026-01-05\n    schedule:\n", 1))
+	require.NoError(t, err)
+	_, err = loadConfig(t, rrule("FREQ=WEEKLY;COUNT=4"))
+	assert.Error(t, err)
+}
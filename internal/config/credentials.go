@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// #nosec G101 -- These are environment variable names, not credentials
+const (
+	envPrefix      = "TASKSEED_CALDAV_"
+	envUsernameVar = envPrefix + "USERNAME"
+	envPasswordVar = envPrefix + "PASSWORD"
+	envNetrcVar    = "NETRC"
+)
+
+// passwordCommandTimeout bounds how long a password command may run.
+const passwordCommandTimeout = 30 * time.Second
+
+// resolveCredentials fills in the username and password of every server. The
+// password is taken from the first source that is configured: inline password,
+// passwordFile, passwordCommand, environment variables, then netrc.
+func resolveCredentials(ctx context.Context, cfg *Config, configDir string) error {
+	for _, name := range cfg.ServerNames() {
+		server := cfg.Servers[name]
+		if err := ResolveServerCredentials(ctx, name, &server, configDir); err != nil {
+			return err
+		}
+		cfg.Servers[name] = server
+		slog.Debug("resolved server credentials", "server", name, "source", server.CredentialSource)
+	}
+	return nil
+}
+
+// ResolveServerCredentials fills in the username and password of a single server
+// named name, using the same sources as configuration loading.
+func ResolveServerCredentials(ctx context.Context, name string, server *ServerConfig, configDir string) error {
+	usernameVar, passwordVar := credentialEnvVars(name)
+	envUsername, envPassword := os.Getenv(usernameVar), os.Getenv(passwordVar)
+
+	switch {
+	case server.Password != "":
+		server.CredentialSource = CredentialSourceConfig
+	case server.PasswordFile != "":
+		password, err := readPasswordFile(server.PasswordFile, configDir)
+		if err != nil {
+			slog.Error("failed to read password file", "server", name, "error", err)
+			return fmt.Errorf("read password file for server %q: %w", name, err)
+		}
+		server.Password, server.CredentialSource = password, CredentialSourcePasswordFile
+	case server.PasswordCommand != "":
+		password, err := runPasswordCommand(ctx, server.PasswordCommand)
+		if err != nil {
+			slog.Error("failed to run password command", "server", name, "error", err)
+			return fmt.Errorf("run password command for server %q: %w", name, err)
+		}
+		server.Password, server.CredentialSource = password, CredentialSourcePasswordCommand
+	case envPassword != "":
+		server.Password, server.CredentialSource = envPassword, CredentialSourceEnv
+	}
+
+	if server.Username == "" {
+		server.Username = envUsername
+	}
+
+	needsUsername := server.Auth.Type == AuthTypeBasic
+	if (needsUsername && server.Username == "") || server.Password == "" {
+		netrc, found, err := lookupNetrc(server.URL.Hostname())
+		if err != nil {
+			return err
+		}
+		if found && server.Password == "" && netrc.password != "" {
+			server.Password, server.CredentialSource = netrc.password, CredentialSourceNetrc
+		}
+		if found && server.Username == "" {
+			server.Username = netrc.login
+		}
+	}
+
+	if (needsUsername && server.Username == "") || server.Password == "" {
+		server.CredentialSource = CredentialSourceNone
+		slog.Error("missing server credentials", "server", name, "username_env", usernameVar, "password_env", passwordVar)
+		return fmt.Errorf("missing credentials for server %q: set password, passwordFile, or passwordCommand, the environment variables %s and %s, or a netrc entry", name, usernameVar, passwordVar)
+	}
+
+	return nil
+}
+
+// credentialEnvVars returns the environment variables holding a server's
+// credentials. Servers other than the default one use the upper-cased server
+// name as an infix, e.g. TASKSEED_CALDAV_NEXTCLOUD_USERNAME.
+func credentialEnvVars(server string) (string, string) {
+	if server == DefaultServerName {
+		return envUsernameVar, envPasswordVar
+	}
+	infix := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, server)
+	return envPrefix + infix + "_USERNAME", envPrefix + infix + "_PASSWORD"
+}
+
+// readPasswordFile reads a password from a file, resolving relative paths
+// against the configuration directory. Trailing line breaks are removed.
+func readPasswordFile(path, configDir string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+
+	raw, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+// runPasswordCommand runs a shell command and returns the first line of its output.
+func runPasswordCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, passwordCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204 -- the command is configured by the user on purpose
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
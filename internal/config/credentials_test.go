@@ -0,0 +1,106 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) ServerConfig {
+	t.Helper()
+	t.Setenv(envNetrcVar, filepath.Join(t.TempDir(), "missing"))
+
+	u, err := url.Parse("https://cal.example.com/dav")
+	require.NoError(t, err)
+	return ServerConfig{URL: u}
+}
+
+func TestResolveServerCredentialsFromPasswordFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "caldav.pass"), []byte("s3cret\n"), 0o600))
+	server := newTestServer(t)
+	server.Username = "alice"
+	server.PasswordFile = "caldav.pass"
+
+	require.NoError(t, ResolveServerCredentials(t.Context(), DefaultServerName, &server, dir))
+
+	assert.Equal(t, "s3cret", server.Password)
+	assert.Equal(t, CredentialSourcePasswordFile, server.CredentialSource)
+}
+
+func TestResolveServerCredentialsFromPasswordCommand(t *testing.T) {
+	server := newTestServer(t)
+	server.Username = "alice"
+	server.PasswordCommand = `printf 's3cret\nurl: example\n'`
+
+	require.NoError(t, ResolveServerCredentials(t.Context(), DefaultServerName, &server, t.TempDir()))
+
+	assert.Equal(t, "s3cret", server.Password)
+	assert.Equal(t, CredentialSourcePasswordCommand, server.CredentialSource)
+}
+
+func TestResolveServerCredentialsFromNamedServerEnv(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("TASKSEED_CALDAV_NEXT_CLOUD_USERNAME", "alice")
+	t.Setenv("TASKSEED_CALDAV_NEXT_CLOUD_PASSWORD", "s3cret")
+
+	require.NoError(t, ResolveServerCredentials(t.Context(), "next-cloud", &server, t.TempDir()))
+
+	assert.Equal(t, "alice", server.Username)
+	assert.Equal(t, "s3cret", server.Password)
+	assert.Equal(t, CredentialSourceEnv, server.CredentialSource)
+}
+
+func TestResolveServerCredentialsFromNetrc(t *testing.T) {
+	server := newTestServer(t)
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(netrc, []byte("machine cal.example.com login alice password s3cret\n"), 0o600))
+	t.Setenv(envNetrcVar, netrc)
+
+	require.NoError(t, ResolveServerCredentials(t.Context(), DefaultServerName, &server, t.TempDir()))
+
+	assert.Equal(t, "alice", server.Username)
+	assert.Equal(t, "s3cret", server.Password)
+	assert.Equal(t, CredentialSourceNetrc, server.CredentialSource)
+}
+
+func TestResolveServerCredentialsWithoutSourceFails(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv(envUsernameVar, "")
+	t.Setenv(envPasswordVar, "")
+
+	err := ResolveServerCredentials(t.Context(), DefaultServerName, &server, t.TempDir())
+
+	require.Error(t, err)
+	assert.Equal(t, CredentialSourceNone, server.CredentialSource)
+}
+
+func TestParseNetrc(t *testing.T) {
+	data := `# personal servers
+machine other.example.com login bob password hunter2
+macdef init
+cd /pub
+quit
+
+machine cal.example.com
+	login alice
+	account home
+	password s3cret
+default login anonymous password guest
+`
+
+	entry, ok := parseNetrc(data, "cal.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, netrcEntry{login: "alice", password: "s3cret"}, entry)
+
+	entry, ok = parseNetrc(data, "unknown.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, netrcEntry{login: "anonymous", password: "guest"}, entry)
+
+	_, ok = parseNetrc("machine other.example.com login bob password hunter2", "cal.example.com")
+	assert.False(t, ok)
+}
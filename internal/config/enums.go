@@ -1,6 +1,14 @@
 package config
 
 //go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=ScheduleKind -trimprefix=ScheduleKind -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=ExclusionPolicy -trimprefix=ExclusionPolicy -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=ShiftMode -trimprefix=ShiftMode -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=MaterializeMode -trimprefix=MaterializeMode -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=PruneAction -trimprefix=PruneAction -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=CredentialSource -trimprefix=CredentialSource -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=AuthType -trimprefix=AuthType -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=Quirk -trimprefix=Quirk -transform=snake
+//go:generate go run github.com/dmarkham/enumer@v1.6.1 -type=DueMode -trimprefix=DueMode -transform=snake
 
 // ScheduleKind enumerates the supported recurrence schedule types.
 type ScheduleKind int
@@ -18,4 +26,111 @@ const (
 	ScheduleKindYearlyDate
 	// ScheduleKindYearlyNthWeekday repeats on the nth weekday of a given month each year.
 	ScheduleKindYearlyNthWeekday
+	// ScheduleKindRrule repeats according to a raw RFC 5545 RRULE.
+	ScheduleKindRrule
+	// ScheduleKindBusinessDays repeats on the nth business day of each month.
+	ScheduleKindBusinessDays
+	// ScheduleKindAfterCompletion repeats a fixed number of days after the last completion.
+	ScheduleKindAfterCompletion
+)
+
+// ExclusionPolicy enumerates how occurrences on excluded dates are handled.
+type ExclusionPolicy int
+
+const (
+	// ExclusionPolicySkip drops occurrences on excluded dates.
+	ExclusionPolicySkip ExclusionPolicy = iota
+	// ExclusionPolicyNextWorkingDay moves occurrences to the next working day.
+	ExclusionPolicyNextWorkingDay
+	// ExclusionPolicyPreviousWorkingDay moves occurrences to the previous working day.
+	ExclusionPolicyPreviousWorkingDay
+)
+
+// ShiftMode enumerates how occurrences on weekends are moved.
+type ShiftMode int
+
+const (
+	// ShiftModeNone keeps occurrences on weekends.
+	ShiftModeNone ShiftMode = iota
+	// ShiftModeNextWeekday moves weekend occurrences to the following Monday.
+	ShiftModeNextWeekday
+	// ShiftModePreviousWeekday moves weekend occurrences to the preceding Friday.
+	ShiftModePreviousWeekday
+)
+
+// MaterializeMode enumerates how many instances of a rule are kept open ahead of time.
+type MaterializeMode int
+
+const (
+	// MaterializeModeNextOnly keeps at most one open instance.
+	MaterializeModeNextOnly MaterializeMode = iota
+	// MaterializeModeAllInHorizon creates every occurrence within the horizon.
+	MaterializeModeAllInHorizon
+	// MaterializeModeCount keeps up to a fixed number of open instances.
+	MaterializeModeCount
+)
+
+// PruneAction enumerates how orphaned tasks are removed.
+type PruneAction int
+
+const (
+	// PruneActionDelete deletes orphaned tasks from the server.
+	PruneActionDelete PruneAction = iota
+	// PruneActionCancel marks orphaned tasks as cancelled.
+	PruneActionCancel
+)
+
+// CredentialSource enumerates where a server's password was read from.
+type CredentialSource int
+
+const (
+	// CredentialSourceNone means no credentials were resolved.
+	CredentialSourceNone CredentialSource = iota
+	// CredentialSourceConfig reads the password inline from the configuration file.
+	CredentialSourceConfig
+	// CredentialSourcePasswordFile reads the password from a file.
+	CredentialSourcePasswordFile
+	// CredentialSourcePasswordCommand reads the password from a command's output.
+	CredentialSourcePasswordCommand
+	// CredentialSourceEnv reads the credentials from environment variables.
+	CredentialSourceEnv
+	// CredentialSourceNetrc reads the credentials from a netrc file.
+	CredentialSourceNetrc
+)
+
+// AuthType enumerates how requests to a server are authenticated.
+type AuthType int
+
+const (
+	// AuthTypeBasic sends the username and password with HTTP basic authentication.
+	AuthTypeBasic AuthType = iota
+	// AuthTypeBearer sends the password as a static bearer token.
+	AuthTypeBearer
+	// AuthTypeOauth2 exchanges the password as refresh token for access tokens.
+	AuthTypeOauth2
+)
+
+// Quirk enumerates server deviations from the CalDAV specification that
+// taskseed works around.
+type Quirk int
+
+const (
+	// QuirkNoVtodoTimerange marks servers whose time-range filters on VTODOs are
+	// unreliable, so the sync window is applied on the client instead.
+	QuirkNoVtodoTimerange Quirk = iota
+)
+
+// DueMode enumerates how due times are written to tasks.
+type DueMode int
+
+const (
+	// DueModeZoned writes local due times qualified with the timezone's TZID.
+	DueModeZoned DueMode = iota
+	// DueModeUtc writes due times converted to UTC.
+	DueModeUtc
+	// DueModeFloating writes local due times without a timezone, so they apply
+	// wherever the user currently is.
+	DueModeFloating
+	// DueModeDate writes date-only due values.
+	DueModeDate
 )
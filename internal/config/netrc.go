@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netrcEntry holds the credentials of a netrc machine or default entry.
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// lookupNetrc returns the netrc entry for host from $NETRC or ~/.netrc. A
+// missing file is not an error.
+func lookupNetrc(host string) (netrcEntry, bool, error) {
+	path := os.Getenv(envNetrcVar)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return netrcEntry{}, false, nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+
+	raw, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return netrcEntry{}, false, nil
+	}
+	if err != nil {
+		slog.Error("failed to read netrc file", "path", path, "error", err)
+		return netrcEntry{}, false, fmt.Errorf("read netrc file %q: %w", path, err)
+	}
+
+	entry, ok := parseNetrc(string(raw), host)
+	return entry, ok, nil
+}
+
+// parseNetrc returns the entry of the first machine matching host, falling back
+// to the default entry. Macro definitions and comment lines are skipped.
+func parseNetrc(data, host string) (netrcEntry, bool) {
+	tokens := netrcTokens(data)
+
+	var match, fallback, current *netrcEntry
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			current = &netrcEntry{}
+			if i+1 < len(tokens) {
+				i++
+				if match == nil && strings.EqualFold(tokens[i], host) {
+					match = current
+				}
+			}
+		case "default":
+			current = &netrcEntry{}
+			if fallback == nil {
+				fallback = current
+			}
+		case "login":
+			if i+1 < len(tokens) {
+				i++
+				if current != nil {
+					current.login = tokens[i]
+				}
+			}
+		case "password":
+			if i+1 < len(tokens) {
+				i++
+				if current != nil {
+					current.password = tokens[i]
+				}
+			}
+		case "account":
+			i++
+		}
+	}
+
+	switch {
+	case match != nil:
+		return *match, true
+	case fallback != nil:
+		return *fallback, true
+	default:
+		return netrcEntry{}, false
+	}
+}
+
+func netrcTokens(data string) []string {
+	var tokens []string
+	inMacro := false
+	for line := range strings.Lines(data) {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for field := range strings.FieldsSeq(line) {
+			if field == "macdef" {
+				inMacro = true
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/goccy/go-yaml"
+
+	"github.com/eikendev/taskseed/internal/timeutil"
 )
 
 var registerParsersOnce sync.Once
@@ -16,10 +18,18 @@ var registerParsersOnce sync.Once
 func RegisterParsers() {
 	registerParsersOnce.Do(func() {
 		yaml.RegisterCustomUnmarshaler(clockTimeUnmarshal)
+		yaml.RegisterCustomUnmarshaler(dateUnmarshal)
 		yaml.RegisterCustomUnmarshaler(locationUnmarshal)
 		yaml.RegisterCustomUnmarshaler(urlUnmarshal)
 		yaml.RegisterCustomUnmarshaler(weekdayUnmarshal)
 		yaml.RegisterCustomUnmarshaler(scheduleKindUnmarshal)
+		yaml.RegisterCustomUnmarshaler(exclusionPolicyUnmarshal)
+		yaml.RegisterCustomUnmarshaler(shiftModeUnmarshal)
+		yaml.RegisterCustomUnmarshaler(materializeModeUnmarshal)
+		yaml.RegisterCustomUnmarshaler(pruneActionUnmarshal)
+		yaml.RegisterCustomUnmarshaler(authTypeUnmarshal)
+		yaml.RegisterCustomUnmarshaler(quirkUnmarshal)
+		yaml.RegisterCustomUnmarshaler(dueModeUnmarshal)
 	})
 }
 
@@ -44,6 +54,10 @@ func clockTimeUnmarshal(ct *ClockTime, data []byte) error {
 	return unmarshalStringInto(ct, data, parseClockTime)
 }
 
+func dateUnmarshal(d *Date, data []byte) error {
+	return unmarshalStringInto(d, data, parseDate)
+}
+
 func locationUnmarshal(loc *time.Location, data []byte) error {
 	return unmarshalStringInto(loc, data, time.LoadLocation)
 }
@@ -60,6 +74,34 @@ func scheduleKindUnmarshal(kind *ScheduleKind, data []byte) error {
 	return unmarshalStringInto(kind, data, parseScheduleKind)
 }
 
+func exclusionPolicyUnmarshal(policy *ExclusionPolicy, data []byte) error {
+	return unmarshalStringInto(policy, data, parseExclusionPolicy)
+}
+
+func shiftModeUnmarshal(mode *ShiftMode, data []byte) error {
+	return unmarshalStringInto(mode, data, parseShiftMode)
+}
+
+func materializeModeUnmarshal(mode *MaterializeMode, data []byte) error {
+	return unmarshalStringInto(mode, data, parseMaterializeMode)
+}
+
+func pruneActionUnmarshal(action *PruneAction, data []byte) error {
+	return unmarshalStringInto(action, data, parsePruneAction)
+}
+
+func authTypeUnmarshal(authType *AuthType, data []byte) error {
+	return unmarshalStringInto(authType, data, parseAuthType)
+}
+
+func quirkUnmarshal(quirk *Quirk, data []byte) error {
+	return unmarshalStringInto(quirk, data, parseQuirk)
+}
+
+func dueModeUnmarshal(mode *DueMode, data []byte) error {
+	return unmarshalStringInto(mode, data, parseDueMode)
+}
+
 func parseClockTime(val string) (*ClockTime, error) {
 	t, err := time.Parse("15:04", val)
 	if err != nil {
@@ -68,6 +110,14 @@ func parseClockTime(val string) (*ClockTime, error) {
 	return &ClockTime{Hour: t.Hour(), Minute: t.Minute()}, nil
 }
 
+func parseDate(val string) (*Date, error) {
+	t, err := time.Parse(timeutil.DateLayout, val)
+	if err != nil {
+		return &Date{}, err
+	}
+	return &Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}, nil
+}
+
 var weekdayValues = map[string]time.Weekday{
 	"sunday":    time.Sunday,
 	"monday":    time.Monday,
@@ -93,3 +143,59 @@ func parseScheduleKind(name string) (*ScheduleKind, error) {
 	}
 	return new(kind), nil
 }
+
+func parseExclusionPolicy(name string) (*ExclusionPolicy, error) {
+	policy, err := ExclusionPolicyString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclusion policy %q", name)
+	}
+	return new(policy), nil
+}
+
+func parseShiftMode(name string) (*ShiftMode, error) {
+	mode, err := ShiftModeString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shift mode %q", name)
+	}
+	return new(mode), nil
+}
+
+func parseMaterializeMode(name string) (*MaterializeMode, error) {
+	mode, err := MaterializeModeString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid materialize mode %q", name)
+	}
+	return new(mode), nil
+}
+
+func parsePruneAction(name string) (*PruneAction, error) {
+	action, err := PruneActionString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prune action %q", name)
+	}
+	return new(action), nil
+}
+
+func parseAuthType(name string) (*AuthType, error) {
+	authType, err := AuthTypeString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth type %q", name)
+	}
+	return new(authType), nil
+}
+
+func parseQuirk(name string) (*Quirk, error) {
+	quirk, err := QuirkString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server quirk %q", name)
+	}
+	return new(quirk), nil
+}
+
+func parseDueMode(name string) (*DueMode, error) {
+	mode, err := DueModeString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due mode %q", name)
+	}
+	return new(mode), nil
+}
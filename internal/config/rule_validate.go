@@ -0,0 +1,66 @@
+package config
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func validateRule(sl validator.StructLevel) {
+	rule, ok := sl.Current().Interface().(Rule)
+	if !ok {
+		return
+	}
+	if rule.Count > 0 && rule.StartDate == nil {
+		sl.ReportError(rule.Count, "Count", "count", "startdate", "")
+	}
+	if rule.Count > 0 && rule.Schedule.Kind == ScheduleKindAfterCompletion {
+		sl.ReportError(rule.Count, "Count", "count", "unsupported", "")
+	}
+	if rule.StartDate != nil && rule.EndDate != nil && rule.EndDate.In(time.UTC).Before(rule.StartDate.In(time.UTC)) {
+		sl.ReportError(rule.EndDate, "EndDate", "endDate", "gtefield", "StartDate")
+	}
+}
+
+func validateMaterialize(sl validator.StructLevel) {
+	materialize, ok := sl.Current().Interface().(MaterializeConfig)
+	if !ok {
+		return
+	}
+	if materialize.Mode == MaterializeModeCount && materialize.Count <= 0 {
+		sl.ReportError(materialize.Count, "Count", "count", "gt0", "")
+	}
+}
+
+func validateAuth(sl validator.StructLevel) {
+	auth, ok := sl.Current().Interface().(AuthConfig)
+	if !ok || auth.Type != AuthTypeOauth2 {
+		return
+	}
+	if auth.TokenURL == nil {
+		sl.ReportError(auth.TokenURL, "TokenURL", "tokenURL", "required_if", "Type oauth2")
+	}
+	if auth.ClientID == "" {
+		sl.ReportError(auth.ClientID, "ClientID", "clientID", "required_if", "Type oauth2")
+	}
+}
+
+func validateDue(sl validator.StructLevel) {
+	due, ok := sl.Current().Interface().(DuePreference)
+	if !ok {
+		return
+	}
+	if due.DateOnly && due.Mode != DueModeZoned && due.Mode != DueModeDate {
+		sl.ReportError(due.DateOnly, "DateOnly", "dateOnly", "excluded_with", "Mode")
+	}
+}
+
+func validateDueOverride(sl validator.StructLevel) {
+	due, ok := sl.Current().Interface().(DueOverride)
+	if !ok || due.DateOnly == nil || !*due.DateOnly || due.Mode == nil {
+		return
+	}
+	if *due.Mode != DueModeZoned && *due.Mode != DueModeDate {
+		sl.ReportError(due.DateOnly, "DateOnly", "dateOnly", "excluded_with", "Mode")
+	}
+}
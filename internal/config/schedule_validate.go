@@ -1,6 +1,11 @@
 package config
 
-import "github.com/go-playground/validator/v10"
+import (
+	"log/slog"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/teambition/rrule-go"
+)
 
 var scheduleValidators = map[ScheduleKind]func(validator.StructLevel, RuleSchedule){
 	ScheduleKindWeekly:            validateWeeklySchedule,
@@ -9,6 +14,19 @@ var scheduleValidators = map[ScheduleKind]func(validator.StructLevel, RuleSchedu
 	ScheduleKindMonthlyNthWeekday: validateMonthlyNthWeekdaySchedule,
 	ScheduleKindYearlyDate:        validateYearlyDateSchedule,
 	ScheduleKindYearlyNthWeekday:  validateYearlyNthWeekdaySchedule,
+	ScheduleKindRrule:             validateRRuleSchedule,
+	ScheduleKindBusinessDays:      validateBusinessDaysSchedule,
+	ScheduleKindAfterCompletion:   validateAfterCompletionSchedule,
+}
+
+// intervalKinds lists the period-based kinds that honor the interval setting.
+var intervalKinds = map[ScheduleKind]struct{}{
+	ScheduleKindWeekly:            {},
+	ScheduleKindMonthlyDay:        {},
+	ScheduleKindMonthlyNthWeekday: {},
+	ScheduleKindYearlyDate:        {},
+	ScheduleKindYearlyNthWeekday:  {},
+	ScheduleKindBusinessDays:      {},
 }
 
 func validateRuleSchedule(sl validator.StructLevel) {
@@ -21,6 +39,9 @@ func validateRuleSchedule(sl validator.StructLevel) {
 		sl.ReportError(schedule.Kind, "Kind", "kind", "unknown", "")
 		return
 	}
+	if _, ok := intervalKinds[schedule.Kind]; !ok && schedule.Interval > 1 {
+		sl.ReportError(schedule.Interval, "Interval", "interval", "unsupported", "")
+	}
 	fn(sl, schedule)
 }
 
@@ -44,9 +65,7 @@ func validateMonthlyDaySchedule(sl validator.StructLevel, schedule RuleSchedule)
 }
 
 func validateMonthlyNthWeekdaySchedule(sl validator.StructLevel, schedule RuleSchedule) {
-	if schedule.Nth <= 0 {
-		sl.ReportError(schedule.Nth, "Nth", "nth", "gt0", "")
-	}
+	validateNthWeekday(sl, schedule.Nth)
 }
 
 func validateYearlyDateSchedule(sl validator.StructLevel, schedule RuleSchedule) {
@@ -62,7 +81,71 @@ func validateYearlyNthWeekdaySchedule(sl validator.StructLevel, schedule RuleSch
 	if schedule.Month < 1 || schedule.Month > 12 {
 		sl.ReportError(schedule.Month, "Month", "month", "month", "")
 	}
-	if schedule.Nth <= 0 {
-		sl.ReportError(schedule.Nth, "Nth", "nth", "gt0", "")
+	validateNthWeekday(sl, schedule.Nth)
+}
+
+func validateNthWeekday(sl validator.StructLevel, nth int) {
+	if nth == 0 {
+		sl.ReportError(nth, "Nth", "nth", "ne0", "")
+	}
+	if nth < -5 || nth > 5 {
+		sl.ReportError(nth, "Nth", "nth", "weekofmonth", "")
+	}
+}
+
+func validateBusinessDaysSchedule(sl validator.StructLevel, schedule RuleSchedule) {
+	if schedule.Nth == 0 {
+		sl.ReportError(schedule.Nth, "Nth", "nth", "ne0", "")
+	}
+}
+
+func validateAfterCompletionSchedule(sl validator.StructLevel, schedule RuleSchedule) {
+	if schedule.DaysAfterCompletion <= 0 {
+		sl.ReportError(schedule.DaysAfterCompletion, "DaysAfterCompletion", "daysAfterCompletion", "gt0", "")
+	}
+}
+
+func validateRRuleSchedule(sl validator.StructLevel, schedule RuleSchedule) {
+	if schedule.RRule == "" {
+		sl.ReportError(schedule.RRule, "RRule", "rrule", "required", "")
+		return
+	}
+
+	option, err := rrule.StrToROption(schedule.RRule)
+	if err != nil {
+		slog.Error("failed to parse rrule", "rule", parentRuleID(sl), "rrule", schedule.RRule, "error", err)
+		sl.ReportError(schedule.RRule, "RRule", "rrule", "rrule", "")
+		return
+	}
+	if option.Freq > rrule.DAILY {
+		slog.Error("detected sub-daily rrule frequency", "rule", parentRuleID(sl), "rrule", schedule.RRule, "freq", option.Freq.String())
+		sl.ReportError(schedule.RRule, "RRule", "rrule", "daily", "")
+		return
+	}
+	if option.Count > 0 && option.Dtstart.IsZero() {
+		slog.Error("detected rrule count without dtstart", "rule", parentRuleID(sl), "rrule", schedule.RRule)
+		sl.ReportError(schedule.RRule, "RRule", "rrule", "dtstart", "")
+	}
+	if option.Interval > 1 && option.Dtstart.IsZero() {
+		// Without a start, the first run fixes the phase of the interval.
+		if rule, ok := parentRule(sl); ok && rule.StartDate == nil {
+			slog.Error("detected rrule interval without dtstart or startDate", "rule", rule.ID, "rrule", schedule.RRule)
+			sl.ReportError(schedule.RRule, "RRule", "rrule", "dtstart", "")
+		}
+	}
+}
+
+func parentRuleID(sl validator.StructLevel) string {
+	rule, _ := parentRule(sl)
+	return rule.ID
+}
+
+// parentRule returns the rule a schedule belongs to.
+func parentRule(sl validator.StructLevel) (Rule, bool) {
+	parent := sl.Parent()
+	if !parent.IsValid() || !parent.CanInterface() {
+		return Rule{}, false
 	}
+	rule, ok := parent.Interface().(Rule)
+	return rule, ok
 }
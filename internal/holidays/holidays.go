@@ -0,0 +1,74 @@
+// Package holidays reads non-working days from iCalendar files.
+package holidays
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/emersion/go-ical"
+
+	"github.com/eikendev/taskseed/internal/timeutil"
+)
+
+// Parse returns the dates covered by the events of an iCalendar stream.
+// Recurring events are expanded up to and including until.
+func Parse(r io.Reader, until time.Time) ([]time.Time, error) {
+	cal, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		slog.Error("failed to decode holiday calendar", "error", err)
+		return nil, fmt.Errorf("decode holiday calendar: %w", err)
+	}
+
+	var out []time.Time
+	for _, event := range cal.Events() {
+		dates, err := eventDates(event, until)
+		if err != nil {
+			uid, _ := event.Props.Text(ical.PropUID)
+			slog.Error("failed to read holiday event", "uid", uid, "error", err)
+			return nil, fmt.Errorf("read holiday event %q: %w", uid, err)
+		}
+		out = append(out, dates...)
+	}
+
+	return out, nil
+}
+
+func eventDates(event ical.Event, until time.Time) ([]time.Time, error) {
+	start, err := event.DateTimeStart(time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parse start: %w", err)
+	}
+	end, err := event.DateTimeEnd(time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parse end: %w", err)
+	}
+	length := end.Sub(start)
+
+	starts := []time.Time{start}
+	set, err := event.RecurrenceSet(time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parse recurrence: %w", err)
+	}
+	if set != nil {
+		starts = set.Between(start, until, true)
+	}
+
+	var out []time.Time
+	for _, s := range starts {
+		out = append(out, spannedDates(s, s.Add(length))...)
+	}
+
+	return out, nil
+}
+
+// spannedDates returns every date touched by the half-open interval [start, end).
+func spannedDates(start, end time.Time) []time.Time {
+	first := timeutil.DateAt(start)
+	out := []time.Time{first}
+	for t := first.AddDate(0, 0, 1); t.Before(end); t = t.AddDate(0, 0, 1) {
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package holidays
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func calendar(events ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN"}
+	lines = append(lines, events...)
+	lines = append(lines, "END:VCALENDAR", "")
+	return strings.Join(lines, "\r\n")
+}
+
+func TestParseReturnsSingleDayEvents(t *testing.T) {
+	input := calendar(
+		"BEGIN:VEVENT", "UID:xmas", "DTSTAMP:20230101T000000Z", "DTSTART;VALUE=DATE:20231225", "END:VEVENT",
+	)
+	expected := []time.Time{date(2023, time.December, 25)}
+
+	got, err := Parse(strings.NewReader(input), date(2024, time.December, 31))
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestParseExpandsMultiDayEvents(t *testing.T) {
+	input := calendar(
+		"BEGIN:VEVENT", "UID:break", "DTSTAMP:20230101T000000Z",
+		"DTSTART;VALUE=DATE:20231225", "DTEND;VALUE=DATE:20231227", "END:VEVENT",
+	)
+	expected := []time.Time{date(2023, time.December, 25), date(2023, time.December, 26)}
+
+	got, err := Parse(strings.NewReader(input), date(2024, time.December, 31))
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestParseExpandsRecurringEventsUntilLimit(t *testing.T) {
+	input := calendar(
+		"BEGIN:VEVENT", "UID:newyear", "DTSTAMP:20230101T000000Z",
+		"DTSTART;VALUE=DATE:20230101", "RRULE:FREQ=YEARLY", "END:VEVENT",
+	)
+	expected := []time.Time{date(2023, time.January, 1), date(2024, time.January, 1), date(2025, time.January, 1)}
+
+	got, err := Parse(strings.NewReader(input), date(2025, time.June, 30))
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestParseInvalidCalendarReturnsError(t *testing.T) {
+	_, err := Parse(strings.NewReader("not a calendar"), date(2025, time.June, 30))
+
+	assert.Error(t, err)
+}
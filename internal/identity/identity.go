@@ -5,11 +5,23 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
-// InstanceID returns a deterministic identifier for a calendar, rule, and occurrence.
-func InstanceID(calendarURL, ruleID, occurrence string) string {
-	canonical := fmt.Sprintf("%s|%s|%s", calendarURL, ruleID, occurrence)
+// Version is the identity scheme used by InstanceID. It is recorded on every
+// created task so that later schemes can recognize older identifiers.
+const Version = 2
+
+// InstanceID returns a deterministic identifier for a namespace, rule, and occurrence.
+// The namespace names a task list independently of the server it is reached through.
+func InstanceID(namespace, ruleID, occurrence string) string {
+	canonical := fmt.Sprintf("%d|%s|%s|%s", Version, namespace, ruleID, occurrence)
 	hash := sha256.Sum256([]byte(canonical))
 	return hex.EncodeToString(hash[:])
 }
+
+// ContentHash returns a deterministic fingerprint of the given content fields.
+func ContentHash(fields ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,16 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceIDDependsOnlyOnNamespaceRuleAndOccurrence(t *testing.T) {
+	home := InstanceID("home", "water_plants", "2023-03-01")
+
+	assert.Len(t, home, 64)
+	assert.Equal(t, home, InstanceID("home", "water_plants", "2023-03-01"))
+	assert.NotEqual(t, home, InstanceID("work", "water_plants", "2023-03-01"))
+	assert.NotEqual(t, home, InstanceID("home", "water_plants", "2023-03-02"))
+}
@@ -0,0 +1,74 @@
+// Package pruner removes tasks whose rules no longer exist in the configuration.
+package pruner
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+// Options control pruning behavior.
+type Options struct {
+	DryRun bool
+	Action config.PruneAction
+	Limit  int
+}
+
+// Orphans returns open taskseed tasks whose rule is not part of the configuration,
+// neither by ID nor by alias. Completed and cancelled tasks are kept as history.
+func Orphans(tasks []caldav.Task, rules []config.Rule) []caldav.Task {
+	known := config.CanonicalRuleIDs(rules)
+
+	var out []caldav.Task
+	for _, t := range tasks {
+		if t.RuleID == "" || t.Completed || t.Cancelled {
+			continue
+		}
+		if _, ok := known[t.RuleID]; ok {
+			continue
+		}
+		out = append(out, t)
+	}
+
+	return out
+}
+
+// Prune deletes or cancels orphaned tasks, removing at most opts.Limit tasks per run.
+// It returns the number of tasks removed; per-task failures are logged.
+func Prune(ctx context.Context, client *caldav.Client, tasks []caldav.Task, rules []config.Rule, opts Options) int {
+	orphans := Orphans(tasks, rules)
+	slog.Debug("found orphaned tasks", "count", len(orphans))
+
+	if opts.Limit > 0 && len(orphans) > opts.Limit {
+		slog.Warn("reached prune limit", "orphans", len(orphans), "limit", opts.Limit)
+		orphans = orphans[:opts.Limit]
+	}
+
+	removed := 0
+	for _, t := range orphans {
+		slog.Info("planned task removal", "rule", t.RuleID, "occurrence", t.Occurrence, "summary", t.Summary, "action", opts.Action)
+
+		if opts.DryRun {
+			slog.Info("skipping task removal", "rule", t.RuleID, "occurrence", t.Occurrence, "reason", "dry_run")
+			continue
+		}
+
+		var err error
+		if opts.Action == config.PruneActionCancel {
+			err = client.CancelTask(ctx, t)
+		} else {
+			err = client.DeleteTask(ctx, t)
+		}
+		if err != nil {
+			slog.Error("failed to remove task", "rule", t.RuleID, "occurrence", t.Occurrence, "error", err)
+			continue
+		}
+
+		removed++
+		slog.Info("removed task", "rule", t.RuleID, "occurrence", t.Occurrence, "id", t.InstanceID, "action", opts.Action)
+	}
+
+	return removed
+}
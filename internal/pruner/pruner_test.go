@@ -0,0 +1,117 @@
+package pruner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+func TestOrphansReturnsOpenTasksOfUnknownRules(t *testing.T) {
+	rules := []config.Rule{{ID: "water_plants"}}
+	tasks := []caldav.Task{
+		{InstanceID: "a", RuleID: "water_plants"},
+		{InstanceID: "b", RuleID: "removed"},
+		{InstanceID: "c", RuleID: "removed", Completed: true},
+		{InstanceID: "d", RuleID: "removed", Cancelled: true},
+		{InstanceID: "e"},
+	}
+
+	got := Orphans(tasks, rules)
+
+	assert.Equal(t, []caldav.Task{{InstanceID: "b", RuleID: "removed"}}, got)
+}
+
+func TestOrphansKeepsTasksOfAliasedRules(t *testing.T) {
+	rules := []config.Rule{{ID: "water_plants", Aliases: []string{"plants"}}}
+	tasks := []caldav.Task{{InstanceID: "a", RuleID: "plants"}}
+
+	assert.Empty(t, Orphans(tasks, rules))
+}
+
+func TestPruneDryRunRemovesNothing(t *testing.T) {
+	tasks := []caldav.Task{{InstanceID: "a", RuleID: "removed"}, {InstanceID: "b", RuleID: "removed"}}
+
+	got := Prune(t.Context(), nil, tasks, nil, Options{DryRun: true, Limit: 1})
+
+	assert.Equal(t, 0, got)
+}
+
+// fakeServer serves the open tasks of rule "removed" listed in ids and records every write.
+func fakeServer(t *testing.T, ids ...string) (*caldav.Client, *[]string) {
+	t.Helper()
+
+	var writes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "REPORT" {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		var out strings.Builder
+		out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+		for _, id := range ids {
+			data := strings.Join([]string{
+				"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO",
+				"UID:" + id, "DTSTAMP:20240101T000000Z", "SUMMARY:Removed",
+				"X-TASKSEED-ID:" + id, "X-TASKSEED-RULE:removed", "X-TASKSEED-OCC:2024-01-01",
+				"END:VTODO", "END:VCALENDAR", "",
+			}, "\r\n")
+			fmt.Fprintf(&out, `<d:response><d:href>/dav/tasks/%s.ics</d:href><d:propstat><d:prop><d:getetag>"1"</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, id, data)
+		}
+		out.WriteString(`</d:multistatus>`)
+		_, _ = io.WriteString(w, out.String())
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := caldav.NewClient(server.URL+"/dav/", http.DefaultTransport)
+	require.NoError(t, err)
+	client, err = client.WithCalendar(server.URL + "/dav/tasks/")
+	require.NoError(t, err)
+
+	return client, &writes
+}
+
+func TestPruneDeletesOrphans(t *testing.T) {
+	client, writes := fakeServer(t, "a", "b")
+	tasks, err := client.QueryOpenTaggedTasks(t.Context())
+	require.NoError(t, err)
+
+	got := Prune(t.Context(), client, tasks, nil, Options{Action: config.PruneActionDelete, Limit: 10})
+
+	assert.Equal(t, 2, got)
+	assert.ElementsMatch(t, []string{"DELETE /dav/tasks/a.ics", "DELETE /dav/tasks/b.ics"}, *writes)
+}
+
+func TestPruneCancelsOrphans(t *testing.T) {
+	client, writes := fakeServer(t, "a")
+	tasks, err := client.QueryOpenTaggedTasks(t.Context())
+	require.NoError(t, err)
+
+	got := Prune(t.Context(), client, tasks, nil, Options{Action: config.PruneActionCancel, Limit: 10})
+
+	assert.Equal(t, 1, got)
+	assert.Equal(t, []string{"PUT /dav/tasks/a.ics"}, *writes)
+}
+
+func TestPruneStopsAtLimit(t *testing.T) {
+	client, writes := fakeServer(t, "a", "b", "c")
+	tasks, err := client.QueryOpenTaggedTasks(t.Context())
+	require.NoError(t, err)
+
+	got := Prune(t.Context(), client, tasks, nil, Options{Action: config.PruneActionDelete, Limit: 2})
+
+	assert.Equal(t, 2, got)
+	assert.Len(t, *writes, 2)
+}
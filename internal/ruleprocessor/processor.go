@@ -5,6 +5,7 @@ import (
 	"context"
 	"log/slog"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/eikendev/taskseed/internal/caldav"
@@ -16,116 +17,379 @@ import (
 
 // Processor manages rule evaluation state and creates tasks when needed.
 type Processor struct {
-	calendarURL   string
-	windowEnd     time.Time
-	existingIDs   map[string]struct{}
-	openByRule    map[string]bool
-	lastOccByRule map[string]*time.Time
-	client        *caldav.Client
-	dryRun        bool
-	timezone      *time.Location
-	due           config.DuePreference
-}
-
-// New constructs a Processor using the provided configuration and client.
-func New(cfg config.Config, client *caldav.Client, dryRun bool) *Processor {
+	namespace            string
+	horizonDays          int
+	windowEnd            time.Time
+	queryEnd             time.Time
+	existingIDs          map[string]struct{}
+	occurrencesByRule    map[string]map[string]struct{}
+	canonicalIDs         map[string]string
+	openByRule           map[string]int
+	lastOccByRule        map[string]*time.Time
+	lastCompletionByRule map[string]*time.Time
+	openTasksByRule      map[string][]caldav.Task
+	client               *caldav.Client
+	dryRun               bool
+	timezone             *time.Location
+	holidays             []config.Date
+	stats                Stats
+}
+
+// Stats counts the task writes performed by a Processor.
+type Stats struct {
+	Created int
+	// Collisions counts tasks that already existed on the server, for example
+	// outside the query window or because another writer created them first.
+	Collisions int
+}
+
+// summary aggregates the existing tasks of a task list per rule.
+type summary struct {
+	ids            map[string]struct{}
+	occurrences    map[string]map[string]struct{}
+	open           map[string]int
+	lastOcc        map[string]*time.Time
+	lastCompletion map[string]*time.Time
+	openTasks      map[string][]caldav.Task
+}
+
+// New constructs a Processor for one target using the provided configuration and client.
+// Rules with their own timezone evaluate today and the horizon in that timezone.
+func New(cfg config.Config, target config.TargetConfig, client *caldav.Client, dryRun bool) *Processor {
 	timezone := cfg.Defaults.Timezone
 	if timezone == nil {
 		timezone = time.UTC
 	}
 
-	today := timeutil.DateAt(time.Now().In(timezone))
-	windowEnd := today.AddDate(0, 0, cfg.Sync.HorizonDays)
-	normalizedEnd := timeutil.DateAt(windowEnd.In(timezone))
+	windowEnd := horizonEnd(timezone, cfg.Sync.HorizonDays)
+	queryEnd := windowEnd
+	for _, rule := range cfg.Rules {
+		if rule.Timezone == nil {
+			continue
+		}
+		if end := horizonEnd(rule.Timezone, cfg.Sync.HorizonDays); end.After(queryEnd) {
+			queryEnd = end
+		}
+	}
 
 	return &Processor{
-		calendarURL:   cfg.Target.URL.String(),
-		windowEnd:     normalizedEnd,
-		existingIDs:   make(map[string]struct{}),
-		openByRule:    make(map[string]bool),
-		lastOccByRule: make(map[string]*time.Time),
-		client:        client,
-		dryRun:        dryRun,
-		timezone:      timezone,
-		due:           cfg.Defaults.Due,
+		namespace:            target.Namespace,
+		horizonDays:          cfg.Sync.HorizonDays,
+		windowEnd:            windowEnd,
+		queryEnd:             queryEnd,
+		existingIDs:          make(map[string]struct{}),
+		occurrencesByRule:    make(map[string]map[string]struct{}),
+		canonicalIDs:         config.CanonicalRuleIDs(cfg.Rules),
+		openByRule:           make(map[string]int),
+		lastOccByRule:        make(map[string]*time.Time),
+		lastCompletionByRule: make(map[string]*time.Time),
+		openTasksByRule:      make(map[string][]caldav.Task),
+		client:               client,
+		dryRun:               dryRun,
+		timezone:             timezone,
+		holidays:             cfg.Defaults.HolidayDates,
 	}
 }
 
-// LoadExisting summarizes the current task list for rule evaluation.
+// LoadExisting summarizes the current task list for rule evaluation. Tasks
+// stored under a rule alias are attributed to the rule carrying the alias.
 func (p *Processor) LoadExisting(tasks []caldav.Task) {
-	p.existingIDs, p.openByRule, p.lastOccByRule = summarize(tasks, p.timezone)
-	slog.Debug("summarized existing tasks", "instances", len(p.existingIDs), "rules_with_open", len(p.openByRule), "rules_with_occurrence", len(p.lastOccByRule))
+	sum := summarize(canonicalize(tasks, p.canonicalIDs), p.timezone)
+	p.existingIDs, p.openByRule, p.lastOccByRule, p.lastCompletionByRule = sum.ids, sum.open, sum.lastOcc, sum.lastCompletion
+	p.occurrencesByRule, p.openTasksByRule = sum.occurrences, sum.openTasks
+
+	legacy := 0
+	for _, t := range tasks {
+		if t.InstanceID != "" && t.IdentityVersion < identity.Version {
+			legacy++
+		}
+	}
+	if legacy > 0 {
+		slog.Debug("recognized tasks with legacy identity", "count", legacy, "identity_version", identity.Version)
+	}
+	slog.Debug("summarized existing tasks", "instances", len(p.existingIDs), "rules_with_open", len(p.openByRule), "rules_with_occurrence", len(p.lastOccByRule), "rules_with_completion", len(p.lastCompletionByRule))
 }
 
-// WindowEnd returns the last day included in the rule evaluation window.
+// WindowEnd returns the last day included in the evaluation window of any rule.
 func (p *Processor) WindowEnd() time.Time {
-	return p.windowEnd
+	return p.queryEnd
 }
 
-// Timezone returns the location used for date calculations.
+// Timezone returns the default location used for date calculations.
 func (p *Processor) Timezone() *time.Location {
 	return p.timezone
 }
 
-// ProcessRule evaluates a rule and creates a task if needed.
+// Stats returns the writes performed so far.
+func (p *Processor) Stats() Stats {
+	return p.stats
+}
+
+// ProcessRule evaluates a rule, updates open instances that drifted from it,
+// and creates tasks as allowed by its materialize settings.
 func (p *Processor) ProcessRule(ctx context.Context, rule config.Rule) {
+	p.updateDrifted(ctx, rule)
+
 	lastOcc := timeutil.FormatDate(p.lastOccByRule[rule.ID])
-	slog.Debug("processing rule", "rule", rule.ID, "schedule_kind", rule.Schedule.Kind, "last_occurrence", lastOcc, "has_open_task", p.openByRule[rule.ID])
+	open := p.openByRule[rule.ID]
+	limit := openLimit(rule)
+	slog.Debug("processing rule", "rule", rule.ID, "schedule_kind", rule.Schedule.Kind, "last_occurrence", lastOcc, "open_tasks", open, "open_limit", limit)
+
+	remaining := 0
+	if limit > 0 {
+		remaining = limit - open
+		if remaining <= 0 {
+			slog.Info("skipping rule", "rule", rule.ID, "reason", "open_task")
+			return
+		}
+	}
 
-	if p.openByRule[rule.ID] {
-		slog.Info("skipping rule", "rule", rule.ID, "reason", "open_task")
+	candidates := p.candidates(rule, p.anchorFor(rule), remaining)
+	if len(candidates) == 0 {
+		slog.Info("no occurrences to create", "rule", rule.ID, "last_occurrence", lastOcc, "window_end", p.windowEndFor(p.timezoneFor(rule)).Format(timeutil.DateLayout))
 		return
 	}
 
-	candidate, ok := p.nextCandidate(rule, p.lastOccByRule[rule.ID])
+	for _, candidate := range candidates {
+		p.createTask(ctx, rule, candidate)
+	}
+}
+
+func (p *Processor) createTask(ctx context.Context, rule config.Rule, occ time.Time) {
+	dueDay, ok := schedule.DueDate(rule.Schedule, occ, p.holidays)
 	if !ok {
-		slog.Info("no occurrences to create", "rule", rule.ID, "last_occurrence", lastOcc, "window_end", p.windowEnd.Format(timeutil.DateLayout))
 		return
 	}
-
-	task := buildTask(rule, candidate, p.calendarURL, p.due, p.timezone)
+	task := buildTask(rule, occ, dueDay, p.namespace, rule.EffectiveDue, p.timezoneFor(rule))
 
 	if p.dryRun {
-		slog.Info("skipping task creation", "rule", rule.ID, "reason", "dry_run")
+		slog.Info("skipping task creation", "rule", rule.ID, "occurrence", task.Occurrence, "reason", "dry_run")
 		return
 	}
 
 	err := p.client.CreateTask(ctx, task)
-	if err != nil {
+	switch {
+	case caldav.IsPreconditionFailed(err):
+		p.stats.Collisions++
+		slog.Warn("skipping task creation", "rule", rule.ID, "occurrence", task.Occurrence, "id", task.UID, "reason", "already_exists")
+	case err != nil:
 		slog.Error("failed to create task", "rule", rule.ID, "error", err)
+	default:
+		p.stats.Created++
+		slog.Info("created task", "rule", rule.ID, "occurrence", task.Occurrence, "id", task.UID)
+	}
+}
+
+// updateDrifted rewrites open instances due today or later whose stored content
+// hash no longer matches the rule. Completed and cancelled instances are not
+// open and never touched, neither are instances whose occurrence is now
+// excluded; instances without a stored hash only receive one.
+func (p *Processor) updateDrifted(ctx context.Context, rule config.Rule) {
+	tz := p.timezoneFor(rule)
+	today := timeutil.DateAt(time.Now().In(tz))
+
+	for _, existing := range p.openTasksByRule[rule.ID] {
+		occ, err := time.ParseInLocation(timeutil.DateLayout, existing.Occurrence, tz)
+		if err != nil {
+			continue
+		}
+		dueDay, ok := schedule.DueDate(rule.Schedule, occ, p.holidays)
+		if !ok || dueDay.Before(today) {
+			continue
+		}
+
+		task := buildTask(rule, occ, dueDay, p.namespace, rule.EffectiveDue, tz)
+		if existing.ContentHash == task.ContentHash {
+			continue
+		}
+		if existing.ContentHash == "" {
+			p.adoptContentHash(ctx, rule, existing, task.ContentHash)
+			continue
+		}
+
+		if p.dryRun {
+			slog.Info("skipping task update", "rule", rule.ID, "occurrence", existing.Occurrence, "reason", "dry_run")
+			continue
+		}
+
+		err = p.client.UpdateTask(ctx, existing, task)
+		switch {
+		case caldav.IsPreconditionFailed(err):
+			slog.Warn("skipping task update", "rule", rule.ID, "occurrence", existing.Occurrence, "reason", "changed_concurrently")
+		case err != nil:
+			slog.Error("failed to update task", "rule", rule.ID, "occurrence", existing.Occurrence, "error", err)
+		default:
+			slog.Info("updated task", "rule", rule.ID, "occurrence", existing.Occurrence, "id", existing.InstanceID)
+		}
+	}
+}
+
+// adoptContentHash stores the current content hash on a task created before
+// hashes were recorded. Its content may carry edits made in a client, so it is
+// left as it is and only later rule changes are applied.
+func (p *Processor) adoptContentHash(ctx context.Context, rule config.Rule, existing caldav.Task, hash string) {
+	if p.dryRun {
+		slog.Info("skipping content hash backfill", "rule", rule.ID, "occurrence", existing.Occurrence, "reason", "dry_run")
 		return
 	}
 
-	slog.Info("created task", "rule", rule.ID, "occurrence", task.Occurrence, "id", task.UID)
+	err := p.client.SetContentHash(ctx, existing, hash)
+	switch {
+	case caldav.IsPreconditionFailed(err):
+		slog.Warn("skipping content hash backfill", "rule", rule.ID, "occurrence", existing.Occurrence, "reason", "changed_concurrently")
+	case err != nil:
+		slog.Error("failed to backfill content hash", "rule", rule.ID, "occurrence", existing.Occurrence, "error", err)
+	default:
+		slog.Debug("backfilled content hash", "rule", rule.ID, "occurrence", existing.Occurrence, "id", existing.InstanceID)
+	}
 }
 
-func (p *Processor) nextCandidate(rule config.Rule, lastOccurrence *time.Time) (time.Time, bool) {
-	ruleToday := timeutil.DateAt(time.Now().In(p.timezone))
-	ruleEnd := p.windowEnd
-	occurrences := schedule.Occurrences(rule.Schedule, ruleToday, ruleEnd, p.timezone, lastOccurrence)
-	slog.Debug("computed occurrences", "rule", rule.ID, "count", len(occurrences))
+// openLimit returns how many instances of a rule may be open at once, where
+// zero means no limit. Completion-relative rules always keep a single instance.
+func openLimit(rule config.Rule) int {
+	if rule.Materialize == nil || rule.Schedule.Kind == config.ScheduleKindAfterCompletion {
+		return 1
+	}
 
-	slices.SortFunc(occurrences, time.Time.Compare)
+	switch rule.Materialize.Mode {
+	case config.MaterializeModeAllInHorizon:
+		return 0
+	case config.MaterializeModeCount:
+		return rule.Materialize.Count
+	default:
+		return 1
+	}
+}
+
+// candidates returns the nominal dates of up to limit occurrences due in the
+// window that do not exist yet, or all of them when limit is zero. Existing
+// tasks are matched by instance ID or by rule and nominal occurrence date.
+func (p *Processor) candidates(rule config.Rule, anchor *time.Time, limit int) []time.Time {
+	tz := p.timezoneFor(rule)
+	ruleToday := timeutil.DateAt(time.Now().In(tz))
+	ruleStart, ruleEnd, ok := p.activeWindow(rule, ruleToday)
+	if !ok {
+		slog.Debug("rule inactive in window", "rule", rule.ID, "today", ruleToday.Format(timeutil.DateLayout))
+		return nil
+	}
+
+	occurrences := schedule.Expand(rule.Schedule, ruleStart, ruleEnd, tz, anchor, p.holidays)
+	slog.Debug("computed occurrences", "rule", rule.ID, "count", len(occurrences))
 
+	var out []time.Time
 	for _, occ := range occurrences {
-		if occ.Before(ruleToday) {
+		// Completion-relative occurrences may be moved before the window start and are still due.
+		if occ.Due.Before(ruleStart) && rule.Schedule.Kind != config.ScheduleKindAfterCompletion {
 			continue
 		}
-		occStr := occ.Format(timeutil.DateLayout)
-		id := identity.InstanceID(p.calendarURL, rule.ID, occStr)
+		occStr := occ.Nominal.Format(timeutil.DateLayout)
+		id := identity.InstanceID(p.namespace, rule.ID, occStr)
 		if _, exists := p.existingIDs[id]; exists {
 			continue
 		}
-		return occ, true
+		if _, exists := p.occurrencesByRule[rule.ID][occStr]; exists {
+			continue
+		}
+		out = append(out, occ.Nominal)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
 	}
 
-	return time.Time{}, false
+	return out
+}
+
+// anchorFor returns the date a rule's schedule continues from. Completion-relative
+// rules prefer the latest completion and fall back to the latest occurrence.
+func (p *Processor) anchorFor(rule config.Rule) *time.Time {
+	tz := p.timezoneFor(rule)
+	if rule.Schedule.Kind == config.ScheduleKindAfterCompletion {
+		if completed := p.lastCompletionByRule[rule.ID]; completed != nil {
+			return completed
+		}
+	}
+	if last := p.lastOccByRule[rule.ID]; last != nil {
+		return new(timeutil.SameDateIn(*last, tz))
+	}
+	if rule.StartDate != nil {
+		return new(rule.StartDate.In(tz))
+	}
+	return nil
+}
+
+// timezoneFor returns the location a rule is evaluated in.
+func (p *Processor) timezoneFor(rule config.Rule) *time.Location {
+	if rule.Timezone != nil {
+		return rule.Timezone
+	}
+	return p.timezone
+}
+
+// windowEndFor returns the last day of the evaluation window in tz.
+func (p *Processor) windowEndFor(tz *time.Location) time.Time {
+	if tz == p.timezone {
+		return p.windowEnd
+	}
+	return horizonEnd(tz, p.horizonDays)
+}
+
+// horizonEnd returns the day horizonDays after today in tz.
+func horizonEnd(tz *time.Location, horizonDays int) time.Time {
+	today := timeutil.DateAt(time.Now().In(tz))
+	return timeutil.DateAt(today.AddDate(0, 0, horizonDays))
 }
 
-func summarize(tasks []caldav.Task, timezone *time.Location) (map[string]struct{}, map[string]bool, map[string]*time.Time) {
-	ids := make(map[string]struct{})
-	open := make(map[string]bool)
-	lastOcc := make(map[string]*time.Time)
+// activeWindow narrows the evaluation window to the rule's start date, end
+// date, and occurrence count. It reports false when the rule is inactive.
+func (p *Processor) activeWindow(rule config.Rule, today time.Time) (time.Time, time.Time, bool) {
+	tz := p.timezoneFor(rule)
+	start, end := today, p.windowEndFor(tz)
+
+	if rule.StartDate != nil {
+		if ruleStart := rule.StartDate.In(tz); ruleStart.After(start) {
+			start = ruleStart
+		}
+	}
+	if rule.EndDate != nil {
+		if ruleEnd := rule.EndDate.In(tz); ruleEnd.Before(end) {
+			end = ruleEnd
+		}
+	}
+	if rule.Count > 0 && rule.StartDate != nil {
+		first := rule.StartDate.In(tz)
+		occurrences := schedule.Occurrences(rule.Schedule, first, end, tz, &first, p.holidays)
+		if len(occurrences) >= rule.Count {
+			slices.SortFunc(occurrences, time.Time.Compare)
+			end = occurrences[rule.Count-1]
+		}
+	}
+
+	return start, end, !end.Before(start)
+}
+
+// canonicalize returns a copy of tasks with rule aliases replaced by the ID of
+// the rule they belong to. Unknown rule IDs are kept as they are.
+func canonicalize(tasks []caldav.Task, canonicalIDs map[string]string) []caldav.Task {
+	out := make([]caldav.Task, len(tasks))
+	for i, t := range tasks {
+		if id, ok := canonicalIDs[t.RuleID]; ok && id != t.RuleID {
+			slog.Debug("attributing task to renamed rule", "alias", t.RuleID, "rule", id, "occurrence", t.Occurrence)
+			t.RuleID = id
+		}
+		out[i] = t
+	}
+	return out
+}
+
+func summarize(tasks []caldav.Task, timezone *time.Location) summary {
+	sum := summary{
+		ids:            make(map[string]struct{}),
+		occurrences:    make(map[string]map[string]struct{}),
+		open:           make(map[string]int),
+		lastOcc:        make(map[string]*time.Time),
+		lastCompletion: make(map[string]*time.Time),
+		openTasks:      make(map[string][]caldav.Task),
+	}
 
 	for _, t := range tasks {
 		if t.InstanceID == "" || t.RuleID == "" || t.Occurrence == "" {
@@ -139,47 +403,93 @@ func summarize(tasks []caldav.Task, timezone *time.Location) (map[string]struct{
 			continue
 		}
 
-		ids[t.InstanceID] = struct{}{}
+		sum.ids[t.InstanceID] = struct{}{}
+		if sum.occurrences[t.RuleID] == nil {
+			sum.occurrences[t.RuleID] = make(map[string]struct{})
+		}
+		sum.occurrences[t.RuleID][t.Occurrence] = struct{}{}
 
-		if !t.Completed {
-			open[t.RuleID] = true
+		if !t.Completed && !t.Cancelled {
+			sum.open[t.RuleID]++
+			sum.openTasks[t.RuleID] = append(sum.openTasks[t.RuleID], t)
 		}
 
 		parsedDate := timeutil.DateAt(parsed.In(timezone))
-		if prev, ok := lastOcc[t.RuleID]; !ok || prev == nil || parsedDate.After(*prev) {
+		if prev, ok := sum.lastOcc[t.RuleID]; !ok || prev == nil || parsedDate.After(*prev) {
 			tmp := parsedDate
-			lastOcc[t.RuleID] = &tmp
+			sum.lastOcc[t.RuleID] = &tmp
+		}
+
+		if t.CompletedAt != nil {
+			if prev, ok := sum.lastCompletion[t.RuleID]; !ok || prev == nil || t.CompletedAt.After(*prev) {
+				sum.lastCompletion[t.RuleID] = t.CompletedAt
+			}
 		}
 	}
 
-	return ids, open, lastOcc
+	return sum
 }
 
-func buildTask(rule config.Rule, occ time.Time, calendarURL string, due config.DuePreference, timezone *time.Location) caldav.NewTask {
-	id := identity.InstanceID(calendarURL, rule.ID, occ.Format(timeutil.DateLayout))
-	dueTime := computeDue(occ, due, timezone)
-
-	return caldav.NewTask{
-		UID:        id,
-		Summary:    rule.Title,
-		Notes:      rule.Notes,
-		Due:        dueTime,
-		DateOnly:   due.DateOnly,
-		InstanceID: id,
-		RuleID:     rule.ID,
-		Occurrence: occ.Format(timeutil.DateLayout),
-		Timezone:   timezone.String(),
+// buildTask describes the task for the nominal occurrence occ, which is due on dueDay.
+func buildTask(rule config.Rule, occ, dueDay time.Time, namespace string, due config.DuePreference, timezone *time.Location) caldav.NewTask {
+	id := identity.InstanceID(namespace, rule.ID, occ.Format(timeutil.DateLayout))
+	dueTime := computeDue(dueDay, due, timezone)
+
+	task := caldav.NewTask{
+		UID:             id,
+		Summary:         rule.Title,
+		Notes:           rule.Notes,
+		Due:             dueTime,
+		DueForm:         dueForm(due.Mode),
+		InstanceID:      id,
+		IdentityVersion: identity.Version,
+		RuleID:          rule.ID,
+		Occurrence:      occ.Format(timeutil.DateLayout),
+		Timezone:        timezone.String(),
 	}
+	task.ContentHash = contentHash(task)
+
+	return task
+}
+
+// contentHash fingerprints the rule-derived content of a task to detect drift.
+func contentHash(task caldav.NewTask) string {
+	return identity.ContentHash(
+		task.Summary,
+		task.Notes,
+		task.Due.Format("2006-01-02T15:04"),
+		strconv.Itoa(int(task.DueForm)),
+		task.Timezone,
+	)
 }
 
+// computeDue returns the due time of an occurrence. UTC due times denote the
+// same instant as the configured time in loc; floating ones keep its wall clock.
 func computeDue(occ time.Time, due config.DuePreference, loc *time.Location) time.Time {
 	if loc == nil {
 		loc = time.UTC
 	}
 
-	if due.DateOnly {
+	switch due.Mode {
+	case config.DueModeDate:
 		return time.Date(occ.Year(), occ.Month(), occ.Day(), 0, 0, 0, 0, loc)
+	case config.DueModeUtc:
+		return time.Date(occ.Year(), occ.Month(), occ.Day(), due.Time.Hour, due.Time.Minute, 0, 0, loc).UTC()
+	default:
+		return time.Date(occ.Year(), occ.Month(), occ.Day(), due.Time.Hour, due.Time.Minute, 0, 0, loc)
 	}
+}
 
-	return time.Date(occ.Year(), occ.Month(), occ.Day(), due.Time.Hour, due.Time.Minute, 0, 0, loc)
+// dueForm maps a configured due mode to the form written to the server.
+func dueForm(mode config.DueMode) caldav.DueForm {
+	switch mode {
+	case config.DueModeUtc:
+		return caldav.DueFormUTC
+	case config.DueModeFloating:
+		return caldav.DueFormFloating
+	case config.DueModeDate:
+		return caldav.DueFormDate
+	default:
+		return caldav.DueFormZoned
+	}
 }
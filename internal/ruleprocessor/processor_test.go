@@ -0,0 +1,540 @@
+package ruleprocessor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/identity"
+	"github.com/eikendev/taskseed/internal/schedule"
+	"github.com/eikendev/taskseed/internal/timeutil"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func newTestProcessor(windowEnd time.Time) *Processor {
+	return &Processor{
+		windowEnd:            windowEnd,
+		existingIDs:          make(map[string]struct{}),
+		occurrencesByRule:    make(map[string]map[string]struct{}),
+		openByRule:           make(map[string]int),
+		lastOccByRule:        make(map[string]*time.Time),
+		lastCompletionByRule: make(map[string]*time.Time),
+		openTasksByRule:      make(map[string][]caldav.Task),
+		timezone:             time.UTC,
+	}
+}
+
+func TestActiveWindowUsesHorizonWithoutLimits(t *testing.T) {
+	p := newTestProcessor(date(2023, time.February, 1))
+	rule := config.Rule{ID: "daily", Schedule: config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 1}}
+
+	start, end, ok := p.activeWindow(rule, date(2023, time.January, 1))
+
+	assert.True(t, ok)
+	assert.Equal(t, date(2023, time.January, 1), start)
+	assert.Equal(t, date(2023, time.February, 1), end)
+}
+
+func TestActiveWindowClampsToStartAndEndDate(t *testing.T) {
+	p := newTestProcessor(date(2023, time.December, 31))
+	rule := config.Rule{
+		ID:        "seasonal",
+		Schedule:  config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}},
+		StartDate: &config.Date{Year: 2023, Month: time.April, Day: 1},
+		EndDate:   &config.Date{Year: 2023, Month: time.September, Day: 30},
+	}
+
+	start, end, ok := p.activeWindow(rule, date(2023, time.January, 1))
+
+	assert.True(t, ok)
+	assert.Equal(t, date(2023, time.April, 1), start)
+	assert.Equal(t, date(2023, time.September, 30), end)
+}
+
+func TestActiveWindowInactiveAfterEndDate(t *testing.T) {
+	p := newTestProcessor(date(2023, time.December, 31))
+	rule := config.Rule{
+		ID:       "expired",
+		Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}},
+		EndDate:  &config.Date{Year: 2023, Month: time.March, Day: 31},
+	}
+
+	_, _, ok := p.activeWindow(rule, date(2023, time.June, 1))
+
+	assert.False(t, ok)
+}
+
+func TestActiveWindowStopsAfterCount(t *testing.T) {
+	p := newTestProcessor(date(2023, time.December, 31))
+	rule := config.Rule{
+		ID:        "antibiotics",
+		Schedule:  config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 1},
+		StartDate: &config.Date{Year: 2023, Month: time.January, Day: 1},
+		Count:     10,
+	}
+
+	_, end, ok := p.activeWindow(rule, date(2023, time.January, 5))
+	assert.True(t, ok)
+	assert.Equal(t, date(2023, time.January, 10), end)
+
+	_, _, ok = p.activeWindow(rule, date(2023, time.January, 11))
+	assert.False(t, ok)
+}
+
+func TestAnchorForPrefersCompletionForAfterCompletionRules(t *testing.T) {
+	p := newTestProcessor(date(2023, time.December, 31))
+	completed := time.Date(2023, time.March, 3, 18, 30, 0, 0, time.UTC)
+	lastOcc := date(2023, time.March, 1)
+	p.lastCompletionByRule["kettle"] = &completed
+	p.lastOccByRule["kettle"] = &lastOcc
+	p.lastOccByRule["plants"] = &lastOcc
+
+	kettle := config.Rule{ID: "kettle", Schedule: config.RuleSchedule{Kind: config.ScheduleKindAfterCompletion, DaysAfterCompletion: 30}}
+	plants := config.Rule{ID: "plants", Schedule: config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 3}}
+
+	assert.Equal(t, &completed, p.anchorFor(kettle))
+	assert.Equal(t, &lastOcc, p.anchorFor(plants))
+}
+
+func TestSummarizeTracksLatestCompletion(t *testing.T) {
+	first := time.Date(2023, time.March, 3, 8, 0, 0, 0, time.UTC)
+	second := time.Date(2023, time.April, 2, 8, 0, 0, 0, time.UTC)
+	tasks := []caldav.Task{
+		{InstanceID: "a", RuleID: "kettle", Occurrence: "2023-03-01", Completed: true, CompletedAt: &first},
+		{InstanceID: "b", RuleID: "kettle", Occurrence: "2023-04-01", Completed: true, CompletedAt: &second},
+		{InstanceID: "c", RuleID: "kettle", Occurrence: "2023-05-02"},
+	}
+
+	sum := summarize(tasks, time.UTC)
+
+	assert.Equal(t, &second, sum.lastCompletion["kettle"])
+	assert.Equal(t, 1, sum.open["kettle"])
+	assert.Equal(t, date(2023, time.May, 2), *sum.lastOcc["kettle"])
+}
+
+func TestLoadExistingAttributesAliasedTasksToRenamedRule(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	p.canonicalIDs = config.CanonicalRuleIDs([]config.Rule{{ID: "mondays", Aliases: []string{"monday_chores"}}})
+	rule := config.Rule{ID: "mondays", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+
+	all := p.candidates(rule, nil, 0)
+	assert.GreaterOrEqual(t, len(all), 2)
+
+	occ := all[0].Format(timeutil.DateLayout)
+	p.LoadExisting([]caldav.Task{
+		{InstanceID: identity.InstanceID(p.namespace, "monday_chores", occ), RuleID: "monday_chores", Occurrence: occ},
+	})
+
+	assert.Equal(t, 1, p.openByRule["mondays"])
+	assert.Equal(t, all[0], *p.lastOccByRule["mondays"])
+	assert.Equal(t, all[1:], p.candidates(rule, nil, 0))
+}
+
+func TestCandidatesMatchesLegacyTasksByRuleAndOccurrence(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	rule := config.Rule{ID: "mondays", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+
+	all := p.candidates(rule, nil, 0)
+	assert.GreaterOrEqual(t, len(all), 2)
+
+	occ := all[0].Format(timeutil.DateLayout)
+	p.LoadExisting([]caldav.Task{
+		{InstanceID: "derived-from-an-old-calendar-url", IdentityVersion: 1, RuleID: rule.ID, Occurrence: occ},
+	})
+
+	assert.Equal(t, all[1:], p.candidates(rule, nil, 0))
+}
+
+func TestOpenLimitFollowsMaterializeMode(t *testing.T) {
+	weekly := config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}
+	tests := []struct {
+		name     string
+		rule     config.Rule
+		expected int
+	}{
+		{"unset", config.Rule{Schedule: weekly}, 1},
+		{"next_only", config.Rule{Schedule: weekly, Materialize: &config.MaterializeConfig{Mode: config.MaterializeModeNextOnly}}, 1},
+		{"all_in_horizon", config.Rule{Schedule: weekly, Materialize: &config.MaterializeConfig{Mode: config.MaterializeModeAllInHorizon}}, 0},
+		{"count", config.Rule{Schedule: weekly, Materialize: &config.MaterializeConfig{Mode: config.MaterializeModeCount, Count: 4}}, 4},
+		{"after_completion", config.Rule{
+			Schedule:    config.RuleSchedule{Kind: config.ScheduleKindAfterCompletion, DaysAfterCompletion: 7},
+			Materialize: &config.MaterializeConfig{Mode: config.MaterializeModeAllInHorizon},
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, openLimit(tt.rule))
+		})
+	}
+}
+
+func TestCandidatesRespectsLimitAndExistingInstances(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	rule := config.Rule{ID: "mondays", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+
+	all := p.candidates(rule, nil, 0)
+	assert.GreaterOrEqual(t, len(all), 5)
+
+	p.existingIDs[identity.InstanceID(p.namespace, rule.ID, all[0].Format(timeutil.DateLayout))] = struct{}{}
+
+	got := p.candidates(rule, nil, 4)
+	assert.Equal(t, all[1:5], got)
+}
+
+func TestBuildTaskContentHashTracksRuleContent(t *testing.T) {
+	rule := config.Rule{ID: "trash", Title: "Take out trash"}
+	due := config.DuePreference{Time: config.ClockTime{Hour: 19}}
+	occ := date(2023, time.March, 1)
+
+	base := buildTask(rule, occ, occ, "https://cal.example.com/tasks/", due, time.UTC)
+	same := buildTask(rule, occ, occ, "https://cal.example.com/tasks/", due, time.UTC)
+	retitled := buildTask(config.Rule{ID: "trash", Title: "Take out bins"}, occ, occ, "https://cal.example.com/tasks/", due, time.UTC)
+	moved := buildTask(rule, occ, occ, "https://cal.example.com/tasks/", config.DuePreference{Time: config.ClockTime{Hour: 20}}, time.UTC)
+
+	assert.NotEmpty(t, base.ContentHash)
+	assert.Equal(t, base.ContentHash, same.ContentHash)
+	assert.NotEqual(t, base.ContentHash, retitled.ContentHash)
+	assert.NotEqual(t, base.ContentHash, moved.ContentHash)
+}
+
+func TestBuildTaskFollowsDueMode(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	rule := config.Rule{ID: "standup", Title: "Standup"}
+	occ := time.Date(2024, time.June, 3, 0, 0, 0, 0, loc)
+	at := func(mode config.DueMode) caldav.NewTask {
+		return buildTask(rule, occ, occ, "tasks", config.DuePreference{Time: config.ClockTime{Hour: 9}, Mode: mode}, loc)
+	}
+
+	zoned := at(config.DueModeZoned)
+	utc := at(config.DueModeUtc)
+	floating := at(config.DueModeFloating)
+	dateOnly := at(config.DueModeDate)
+
+	assert.Equal(t, caldav.DueFormZoned, zoned.DueForm)
+	assert.Equal(t, time.Date(2024, time.June, 3, 9, 0, 0, 0, loc), zoned.Due)
+	assert.Equal(t, caldav.DueFormUTC, utc.DueForm)
+	assert.Equal(t, time.Date(2024, time.June, 3, 7, 0, 0, 0, time.UTC), utc.Due)
+	assert.Equal(t, caldav.DueFormFloating, floating.DueForm)
+	assert.Equal(t, 9, floating.Due.Hour())
+	assert.Equal(t, caldav.DueFormDate, dateOnly.DueForm)
+	assert.Equal(t, 0, dateOnly.Due.Hour())
+	assert.NotEqual(t, zoned.ContentHash, floating.ContentHash)
+	assert.NotEqual(t, zoned.ContentHash, utc.ContentHash)
+}
+
+func TestRuleTimezoneDrivesWindowAndAnchor(t *testing.T) {
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	require.NoError(t, err)
+	p := newTestProcessor(date(2023, time.February, 1))
+	p.horizonDays = 30
+	rule := config.Rule{ID: "pills", Timezone: kiritimati, Schedule: config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 1}}
+	p.lastOccByRule[rule.ID] = new(date(2023, time.January, 10))
+
+	today := timeutil.DateAt(time.Now().In(kiritimati))
+	_, end, ok := p.activeWindow(rule, today)
+
+	assert.True(t, ok)
+	assert.Equal(t, today.AddDate(0, 0, 30), end)
+	assert.Equal(t, time.Date(2023, time.January, 10, 0, 0, 0, 0, kiritimati), *p.anchorFor(rule))
+}
+
+func TestShiftedOccurrenceKeepsPhaseAcrossRuns(t *testing.T) {
+	p := newTestProcessor(date(2024, time.December, 31))
+	start := config.Date{Year: 2024, Month: time.June, Day: 1}
+	rule := config.Rule{
+		ID:        "water",
+		StartDate: &start,
+		Schedule:  config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 10, Shift: config.ShiftModeNextWeekday},
+	}
+
+	first := schedule.Expand(rule.Schedule, date(2024, time.June, 1), date(2024, time.June, 5), time.UTC, p.anchorFor(rule), nil)
+	require.Len(t, first, 1)
+	task := buildTask(rule, first[0].Nominal, first[0].Due, "tasks", config.DuePreference{}, time.UTC)
+	assert.Equal(t, "2024-06-01", task.Occurrence)
+	assert.Equal(t, date(2024, time.June, 3), task.Due)
+
+	p.LoadExisting([]caldav.Task{{InstanceID: task.InstanceID, RuleID: rule.ID, Occurrence: task.Occurrence}})
+	second := schedule.Expand(rule.Schedule, date(2024, time.June, 4), date(2024, time.June, 30), time.UTC, p.anchorFor(rule), nil)
+
+	require.Len(t, second, 2)
+	assert.Equal(t, date(2024, time.June, 11), second[0].Due)
+	assert.Equal(t, date(2024, time.June, 21), second[1].Due)
+}
+
+// newTestClient returns a client for the task list /dav/tasks/ on a server handled by handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *caldav.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := caldav.NewClient(server.URL+"/dav/", http.DefaultTransport)
+	require.NoError(t, err)
+	calendar, err := client.WithCalendar(server.URL + "/dav/tasks/")
+	require.NoError(t, err)
+
+	return calendar
+}
+
+// multistatus renders a calendar-query response holding one calendar object per entry of objects.
+func multistatus(objects map[string]string) string {
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+	for path, data := range objects {
+		fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>"1"</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, data)
+	}
+	out.WriteString(`</d:multistatus>`)
+	return out.String()
+}
+
+func TestUpdateDriftedOnlyBackfillsMissingHash(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "tasks"
+	rule := config.Rule{ID: "mondays", Title: "Water plants", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+	occ := p.candidates(rule, nil, 0)[0]
+	id := identity.InstanceID(p.namespace, rule.ID, occ.Format(timeutil.DateLayout))
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO",
+		"UID:" + id, "DTSTAMP:20240101T000000Z", "SUMMARY:Edited in client",
+		"DUE:" + occ.Format("20060102") + "T090000Z",
+		"X-TASKSEED-ID:" + id, "X-TASKSEED-RULE:" + rule.ID, "X-TASKSEED-OCC:" + occ.Format(timeutil.DateLayout),
+		"END:VTODO", "END:VCALENDAR", "",
+	}, "\r\n")
+
+	var puts []string
+	p.client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "REPORT":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = io.WriteString(w, multistatus(map[string]string{"/dav/tasks/" + id + ".ics": data}))
+		case http.MethodPut:
+			raw, _ := io.ReadAll(r.Body)
+			puts = append(puts, string(raw))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	existing, err := p.client.QueryTasks(context.Background(), time.Now(), p.windowEnd)
+	require.NoError(t, err)
+	require.Len(t, existing, 1)
+	p.LoadExisting(existing)
+
+	p.updateDrifted(context.Background(), rule)
+
+	require.Len(t, puts, 1)
+	assert.Contains(t, puts[0], "SUMMARY:Edited in client")
+	assert.Contains(t, puts[0], "X-TASKSEED-HASH")
+}
+
+// storedTask renders the calendar object taskseed writes for occ of rule,
+// including the content hash it records, and returns it with its path.
+func storedTask(p *Processor, rule config.Rule, occ time.Time) (string, string) {
+	task := buildTask(rule, occ, occ, p.namespace, rule.EffectiveDue, time.UTC)
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO",
+		"UID:" + task.UID, "DTSTAMP:20240101T000000Z", "SUMMARY:" + task.Summary,
+		"DUE:" + task.Due.UTC().Format("20060102T150405Z"),
+		"X-TASKSEED-ID:" + task.InstanceID, "X-TASKSEED-RULE:" + rule.ID, "X-TASKSEED-OCC:" + task.Occurrence,
+		"X-TASKSEED-HASH:" + task.ContentHash,
+		"END:VTODO", "END:VCALENDAR", "",
+	}, "\r\n")
+	return "/dav/tasks/" + task.UID + ".ics", data
+}
+
+// loadServed points p at a task list holding objects and loads them as the
+// existing tasks. Requests other than reports are passed to write.
+func loadServed(t *testing.T, p *Processor, objects map[string]string, write http.HandlerFunc) {
+	t.Helper()
+
+	p.client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "REPORT" {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = io.WriteString(w, multistatus(objects))
+			return
+		}
+		write(w, r)
+	})
+
+	existing, err := p.client.QueryTasks(context.Background(), time.Now(), p.windowEnd)
+	require.NoError(t, err)
+	require.Len(t, existing, len(objects))
+	p.LoadExisting(existing)
+}
+
+func TestUpdateDriftedRewritesChangedRuleContent(t *testing.T) {
+	base := config.Rule{
+		ID:           "mondays",
+		Title:        "Water plants",
+		Schedule:     config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}},
+		EffectiveDue: config.DuePreference{Time: config.ClockTime{Hour: 9}},
+	}
+	occ := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35)).candidates(base, nil, 0)[0]
+
+	cases := []struct {
+		name   string
+		change func(rule *config.Rule)
+		want   string
+	}{
+		{"title", func(rule *config.Rule) { rule.Title = "Water all plants" }, "SUMMARY:Water all plants"},
+		{"notes", func(rule *config.Rule) { rule.Notes = "Use rainwater" }, "DESCRIPTION:Use rainwater"},
+		{"due time", func(rule *config.Rule) { rule.EffectiveDue.Time.Hour = 20 }, "DUE:" + occ.Format("20060102") + "T200000Z"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+			p.namespace = "household"
+			path, data := storedTask(p, base, occ)
+
+			type put struct{ path, ifMatch, body string }
+			var puts []put
+			loadServed(t, p, map[string]string{path: data}, func(w http.ResponseWriter, r *http.Request) {
+				raw, _ := io.ReadAll(r.Body)
+				puts = append(puts, put{r.URL.Path, r.Header.Get("If-Match"), string(raw)})
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			rule := base
+			tc.change(&rule)
+			p.updateDrifted(context.Background(), rule)
+
+			require.Len(t, puts, 1)
+			assert.Equal(t, path, puts[0].path)
+			assert.Equal(t, `"1"`, puts[0].ifMatch)
+			assert.Contains(t, puts[0].body, tc.want)
+			task := buildTask(rule, occ, occ, p.namespace, rule.EffectiveDue, time.UTC)
+			assert.Contains(t, puts[0].body, "X-TASKSEED-HASH;VALUE=TEXT:"+task.ContentHash)
+		})
+	}
+}
+
+func TestUpdateDriftedSkipsTaskChangedConcurrently(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	rule := config.Rule{ID: "mondays", Title: "Water plants", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+	all := p.candidates(rule, nil, 0)
+	require.GreaterOrEqual(t, len(all), 2)
+	objects := make(map[string]string)
+	for _, occ := range all[:2] {
+		path, data := storedTask(p, rule, occ)
+		objects[path] = data
+	}
+
+	var puts []string
+	loadServed(t, p, objects, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, `"1"`, r.Header.Get("If-Match"))
+		puts = append(puts, r.URL.Path)
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+
+	rule.Title = "Water all plants"
+	p.updateDrifted(context.Background(), rule)
+
+	assert.ElementsMatch(t, slices.Collect(maps.Keys(objects)), puts)
+	assert.Equal(t, Stats{}, p.Stats())
+}
+
+func TestUpdateDriftedLeavesPastDueInstancesAlone(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	rule := config.Rule{ID: "daily", Title: "Feed the cat", Schedule: config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 1}}
+	occ := timeutil.DateAt(time.Now().UTC()).AddDate(0, 0, -3)
+	path, data := storedTask(p, rule, occ)
+
+	loadServed(t, p, map[string]string{path: data}, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request for %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	require.Len(t, p.openTasksByRule[rule.ID], 1)
+
+	rule.Title = "Feed both cats"
+	p.updateDrifted(context.Background(), rule)
+}
+
+func TestCreateTaskCountsExistingResourceAsCollision(t *testing.T) {
+	statuses := []int{http.StatusPreconditionFailed, http.StatusCreated, http.StatusInternalServerError}
+	p := newTestProcessor(date(2023, time.March, 31))
+	p.namespace = config.DefaultTargetName
+	p.client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "*", r.Header.Get("If-None-Match"))
+		w.WriteHeader(statuses[0])
+		statuses = statuses[1:]
+	})
+	rule := config.Rule{ID: "daily", Title: "Daily", Schedule: config.RuleSchedule{Kind: config.ScheduleKindEveryNDays, EveryNDays: 1}}
+
+	for day := 1; day <= 3; day++ {
+		p.createTask(context.Background(), rule, date(2023, time.March, day))
+	}
+
+	assert.Equal(t, Stats{Created: 1, Collisions: 1}, p.Stats())
+	assert.Empty(t, statuses)
+}
+
+func TestCancelledInstanceIsNeitherOpenNorRewritten(t *testing.T) {
+	p := newTestProcessor(time.Now().UTC().AddDate(0, 0, 35))
+	p.namespace = "household"
+	rule := config.Rule{ID: "mondays", Title: "Water plants", Schedule: config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}}}
+	all := p.candidates(rule, nil, 0)
+	require.GreaterOrEqual(t, len(all), 2)
+	occ := all[0].Format(timeutil.DateLayout)
+	id := identity.InstanceID(p.namespace, rule.ID, occ)
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO",
+		"UID:" + id, "DTSTAMP:20240101T000000Z", "SUMMARY:Old title", "STATUS:CANCELLED",
+		"DUE:" + all[0].Format("20060102") + "T090000Z",
+		"X-TASKSEED-ID:" + id, "X-TASKSEED-RULE:" + rule.ID, "X-TASKSEED-OCC:" + occ, "X-TASKSEED-HASH:stale",
+		"END:VTODO", "END:VCALENDAR", "",
+	}, "\r\n")
+
+	var created, updated []string
+	p.client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "REPORT":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			_, _ = io.WriteString(w, multistatus(map[string]string{"/dav/tasks/" + id + ".ics": data}))
+		case r.Header.Get("If-None-Match") == "*":
+			created = append(created, r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			updated = append(updated, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	existing, err := p.client.QueryTasks(context.Background(), time.Now(), p.windowEnd)
+	require.NoError(t, err)
+	require.Len(t, existing, 1)
+	require.True(t, existing[0].Cancelled)
+
+	p.LoadExisting(existing)
+	assert.Zero(t, p.openByRule[rule.ID])
+
+	p.ProcessRule(context.Background(), rule)
+
+	assert.Empty(t, updated)
+	next := identity.InstanceID(p.namespace, rule.ID, all[1].Format(timeutil.DateLayout))
+	assert.Equal(t, []string{"/dav/tasks/" + next + ".ics"}, created)
+}
@@ -3,14 +3,20 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/eikendev/taskseed/internal/caldav"
 	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/identity"
+	"github.com/eikendev/taskseed/internal/pruner"
 	"github.com/eikendev/taskseed/internal/ruleprocessor"
+	"github.com/eikendev/taskseed/internal/syncstate"
 	"github.com/eikendev/taskseed/internal/timeutil"
+	"github.com/eikendev/taskseed/internal/transport"
 )
 
 // Options control synchronization behavior.
@@ -19,36 +25,379 @@ type Options struct {
 }
 
 // Run performs a reconciliation cycle that reads existing tasks, checks rules,
-// and creates at most one new task per rule when needed.
+// and creates new tasks per rule as allowed by its materialize settings.
+// Each target is queried once and only processes the rules that belong to it.
+// When sync.prune is enabled, tasks of removed rules are pruned afterwards.
+// When sync.stateFile is set, existing tasks are fetched incrementally.
 // Inputs: context for cancellation, a validated config, and runtime options.
-// Output: error when client setup or query fails; per-rule creation errors are logged.
+// Output: error when client setup or query fails for any target; per-rule
+// creation errors are logged.
 func Run(ctx context.Context, cfg config.Config, opts Options) error {
-	client, err := caldav.NewClient(cfg.Server.URL.String(), cfg.Target.URL.String(), cfg.Server.Username, cfg.Server.Password)
+	clients := newClientPool(cfg)
+	state := loadState(cfg)
+	pruneOpts := pruneOptions(cfg, opts)
+
+	var errs []error
+	var stats ruleprocessor.Stats
+	for _, name := range cfg.TargetNames() {
+		client, processor, existing, err := openTarget(ctx, cfg, clients, state, name, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		processor.LoadExisting(existing)
+
+		rules := cfg.RulesFor(name)
+		for _, rule := range rules {
+			processor.ProcessRule(ctx, rule)
+		}
+
+		stats.Created += processor.Stats().Created
+		stats.Collisions += processor.Stats().Collisions
+
+		if cfg.Sync.Prune && pruneOpts.Limit > 0 {
+			n, err := pruneTarget(ctx, client, name, cfg.Targets[name], rules, pruneOpts)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			pruneOpts.Limit -= n
+		}
+	}
+
+	slog.Info("synced tasks", "created", stats.Created, "already_existed", stats.Collisions, "dry_run", opts.DryRun)
+
+	errs = append(errs, saveState(state))
+
+	return errors.Join(errs...)
+}
+
+// Prune removes open tasks whose rules no longer exist in the configuration
+// or were moved to another target.
+// Inputs: context for cancellation, a validated config, and runtime options.
+// Output: error when client setup or query fails for any target; per-task
+// removal errors are logged.
+func Prune(ctx context.Context, cfg config.Config, opts Options) error {
+	clients := newClientPool(cfg)
+	pruneOpts := pruneOptions(cfg, opts)
+
+	var errs []error
+	removed := 0
+	for _, name := range cfg.TargetNames() {
+		if pruneOpts.Limit <= 0 {
+			slog.Warn("skipping target", "target", name, "reason", "prune_limit")
+			continue
+		}
+
+		target := cfg.Targets[name]
+		client, err := clients.forTarget(target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("target %q: %w", name, err))
+			continue
+		}
+
+		n, err := pruneTarget(ctx, client, name, target, cfg.RulesFor(name), pruneOpts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruneOpts.Limit -= n
+		removed += n
+	}
+
+	slog.Info("pruned orphaned tasks", "removed", removed, "dry_run", opts.DryRun)
+
+	return errors.Join(errs...)
+}
+
+// MigrateRule moves the tasks stored under rule ID from to the rule ID to, so
+// that a renamed rule continues its existing series. Every task of the rule is
+// covered regardless of its dates; task lists are never created.
+// Inputs: context for cancellation, a validated config, both rule IDs, and runtime options.
+// Output: error when the target rule is unknown or client setup or query fails;
+// per-task rewrite errors are logged.
+func MigrateRule(ctx context.Context, cfg config.Config, from, to string, opts Options) error {
+	if !slices.ContainsFunc(cfg.Rules, func(rule config.Rule) bool { return rule.ID == to }) {
+		slog.Error("failed to find target rule", "rule", to)
+		return fmt.Errorf("unknown rule id %q", to)
+	}
+
+	clients := newClientPool(cfg)
+	var errs []error
+	migrated := 0
+	for _, name := range cfg.TargetNames() {
+		target := cfg.Targets[name]
+		client, err := clients.forTarget(target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("target %q: %w", name, err))
+			continue
+		}
+		if missingTaskList(ctx, client, target) {
+			slog.Debug("skipping target", "target", name, "reason", "missing_task_list")
+			continue
+		}
+
+		tagged, err := client.QueryAllTaggedTasks(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("target %q: %w", name, err))
+			continue
+		}
+
+		for _, t := range tagged {
+			if t.RuleID != from {
+				continue
+			}
+
+			if opts.DryRun {
+				slog.Info("skipping task migration", "target", name, "from", from, "to", to, "occurrence", t.Occurrence, "reason", "dry_run")
+				continue
+			}
+
+			instanceID := identity.InstanceID(target.Namespace, to, t.Occurrence)
+			if err := client.RetagTask(ctx, t, to, instanceID, identity.Version); err != nil {
+				slog.Error("failed to migrate task", "target", name, "from", from, "to", to, "occurrence", t.Occurrence, "error", err)
+				continue
+			}
+
+			migrated++
+			slog.Info("migrated task", "target", name, "from", from, "to", to, "occurrence", t.Occurrence, "id", instanceID)
+		}
+	}
+
+	slog.Info("migrated rule", "from", from, "to", to, "tasks", migrated, "dry_run", opts.DryRun)
+
+	return errors.Join(errs...)
+}
+
+// clientPool creates one CalDAV client per server and shares it across targets.
+type clientPool struct {
+	servers map[string]config.ServerConfig
+	clients map[string]*caldav.Client
+}
+
+func newClientPool(cfg config.Config) *clientPool {
+	return &clientPool{
+		servers: cfg.Servers,
+		clients: make(map[string]*caldav.Client),
+	}
+}
+
+// forTarget returns a client for the target's task list on its server.
+func (p *clientPool) forTarget(target config.TargetConfig) (*caldav.Client, error) {
+	client, ok := p.clients[target.Server]
+	if !ok {
+		server := p.servers[target.Server]
+		rt, err := transport.New(target.Server, server)
+		if err != nil {
+			return nil, err
+		}
+		client, err = caldav.NewClient(server.URL.String(), rt)
+		if err != nil {
+			slog.Error("failed to create caldav client", "server", target.Server, "error", err)
+			return nil, fmt.Errorf("create caldav client for server %q: %w", target.Server, err)
+		}
+		p.clients[target.Server] = client
+	}
+
+	return client.WithCalendar(target.URL.String())
+}
+
+// openTarget connects to the named target and fetches its existing tasks.
+// A nil state disables incremental synchronization.
+func openTarget(ctx context.Context, cfg config.Config, clients *clientPool, state *syncstate.State, name string, opts Options) (*caldav.Client, *ruleprocessor.Processor, []caldav.Task, error) {
+	target := cfg.Targets[name]
+
+	client, err := clients.forTarget(target)
 	if err != nil {
-		slog.Error("failed to create caldav client", "error", err)
-		return fmt.Errorf("create caldav client: %w", err)
+		return nil, nil, nil, fmt.Errorf("target %q: %w", name, err)
+	}
+
+	processor := ruleprocessor.New(cfg, target, client, opts.DryRun)
+
+	if target.Create {
+		exists, err := ensureTaskList(ctx, client, name, target, opts.DryRun)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("target %q: %w", name, err)
+		}
+		if !exists {
+			return client, processor, nil, nil
+		}
 	}
 
-	processor := ruleprocessor.New(cfg, client, opts.DryRun)
+	existing, err := queryExisting(ctx, cfg, target, client, processor, syncCache(state, target))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("target %q: %w", name, err)
+	}
 
+	existing, err = withCompletionHistory(ctx, client, cfg.RulesFor(name), existing)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("target %q: %w", name, err)
+	}
+
+	return client, processor, existing, nil
+}
+
+// ensureTaskList creates the target's collection when it is missing and
+// reports whether it exists afterwards. Dry runs never create it.
+func ensureTaskList(ctx context.Context, client *caldav.Client, name string, target config.TargetConfig, dryRun bool) (bool, error) {
+	err := client.Probe(ctx)
+	if err == nil {
+		return true, nil
+	}
+	if !caldav.IsNotFound(err) {
+		slog.Error("failed to check task list", "target", name, "error", err)
+		return false, fmt.Errorf("check task list: %w", err)
+	}
+
+	if dryRun {
+		slog.Info("skipping task list creation", "target", name, "reason", "dry_run")
+		return false, nil
+	}
+
+	if err := client.CreateCalendar(ctx, target.DisplayName, target.Color); err != nil {
+		return false, err
+	}
+
+	slog.Info("created task list", "target", name, "display_name", target.DisplayName)
+
+	return true, nil
+}
+
+// queryExisting fetches the tasks within the sync window. With a cache, only
+// objects changed since the last run are downloaded; when incremental sync is
+// unsupported or fails, it falls back to a full calendar query. Servers with
+// unreliable VTODO time-range filters are queried for all tagged tasks instead.
+func queryExisting(ctx context.Context, cfg config.Config, target config.TargetConfig, client *caldav.Client, processor *ruleprocessor.Processor, cache *caldav.SyncCache) ([]caldav.Task, error) {
 	today := timeutil.DateAt(time.Now().In(processor.Timezone()))
 	windowStart := today.AddDate(0, 0, -cfg.Sync.LookbackDays)
 	windowEnd := processor.WindowEnd()
 
+	if cache != nil {
+		slog.Debug("syncing existing tasks", "incremental", cache.Token != "")
+		existing, err := client.SyncTasks(ctx, windowStart, windowEnd, cache)
+		if err == nil {
+			slog.Info("fetched existing tasks", "mode", "sync_collection")
+			return existing, nil
+		}
+		slog.Warn("falling back to full query", "reason", "sync_failed")
+	}
+
+	query := client.QueryTasks
+	if cfg.Servers[target.Server].HasQuirk(config.QuirkNoVtodoTimerange) {
+		query = client.QueryTaggedTasks
+	}
+
 	slog.Debug("querying existing tasks")
-	existing, err := client.QueryTasks(ctx, windowStart, windowEnd)
+	existing, err := query(ctx, windowStart, windowEnd)
 	if err != nil {
 		slog.Error("failed to query existing tasks", "error", err)
-		return fmt.Errorf("query existing tasks: %w", err)
+		return nil, fmt.Errorf("query existing tasks: %w", err)
 	}
 
 	slog.Info("fetched existing tasks")
 
-	processor.LoadExisting(existing)
+	return existing, nil
+}
+
+// pruneTarget removes orphaned tasks from the target. It queries all open
+// tagged tasks, so orphans are found regardless of their due date. A task
+// list that does not exist yet has nothing to prune.
+func pruneTarget(ctx context.Context, client *caldav.Client, name string, target config.TargetConfig, rules []config.Rule, opts pruner.Options) (int, error) {
+	if missingTaskList(ctx, client, target) {
+		slog.Debug("skipping target", "target", name, "reason", "missing_task_list")
+		return 0, nil
+	}
+
+	open, err := client.QueryOpenTaggedTasks(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("target %q: %w", name, err)
+	}
+
+	return pruner.Prune(ctx, client, open, rules, opts), nil
+}
+
+// missingTaskList reports whether the task list of a target that is created
+// on demand does not exist yet.
+func missingTaskList(ctx context.Context, client *caldav.Client, target config.TargetConfig) bool {
+	if !target.Create {
+		return false
+	}
+	return caldav.IsNotFound(client.Probe(ctx))
+}
+
+// withCompletionHistory adds the tasks of completion-relative rules that lie
+// outside the sync window to existing, so that such rules continue from their
+// latest instance however long ago it was due.
+func withCompletionHistory(ctx context.Context, client *caldav.Client, rules []config.Rule, existing []caldav.Task) ([]caldav.Task, error) {
+	ruleIDs := make(map[string]struct{})
+	for _, rule := range rules {
+		if rule.Schedule.Kind != config.ScheduleKindAfterCompletion {
+			continue
+		}
+		ruleIDs[rule.ID] = struct{}{}
+		for _, alias := range rule.Aliases {
+			ruleIDs[alias] = struct{}{}
+		}
+	}
+	if len(ruleIDs) == 0 {
+		return existing, nil
+	}
+
+	tagged, err := client.QueryAllTaggedTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query completion history: %w", err)
+	}
+
+	known := make(map[string]struct{}, len(existing))
+	for _, t := range existing {
+		known[t.Path] = struct{}{}
+	}
 
-	for _, rule := range cfg.Rules {
-		processor.ProcessRule(ctx, rule)
+	added := 0
+	for _, t := range tagged {
+		if _, ok := ruleIDs[t.RuleID]; !ok {
+			continue
+		}
+		if _, ok := known[t.Path]; ok {
+			continue
+		}
+		existing = append(existing, t)
+		added++
 	}
+	slog.Debug("fetched completion history", "tasks", added)
 
-	return nil
+	return existing, nil
+}
+
+// loadState reads the sync state file, or returns nil when none is configured.
+func loadState(cfg config.Config) *syncstate.State {
+	if cfg.Sync.StateFile == "" {
+		return nil
+	}
+	return syncstate.Load(cfg.Sync.StateFile)
+}
+
+// saveState persists the sync state, if any.
+func saveState(state *syncstate.State) error {
+	if state == nil {
+		return nil
+	}
+	return state.Save()
+}
+
+// syncCache returns the target's cache from state, or nil without state.
+func syncCache(state *syncstate.State, target config.TargetConfig) *caldav.SyncCache {
+	if state == nil {
+		return nil
+	}
+	return state.Collection(target.URL.String())
+}
+
+func pruneOptions(cfg config.Config, opts Options) pruner.Options {
+	return pruner.Options{
+		DryRun: opts.DryRun,
+		Action: cfg.Sync.PruneAction,
+		Limit:  *cfg.Sync.PruneLimit,
+	}
 }
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+	"github.com/eikendev/taskseed/internal/config"
+	"github.com/eikendev/taskseed/internal/ruleprocessor"
+)
+
+// todoData renders a calendar object holding a single VTODO with the given extra properties.
+func todoData(uid string, props ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO", "UID:" + uid, "DTSTAMP:20240101T000000Z"}
+	lines = append(lines, props...)
+	lines = append(lines, "END:VTODO", "END:VCALENDAR", "")
+	return strings.Join(lines, "\r\n")
+}
+
+// writeMultistatus answers a report with one calendar object per entry of objects.
+func writeMultistatus(w http.ResponseWriter, objects map[string]string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+	for path, data := range objects {
+		fmt.Fprintf(&out, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>"1"</d:getetag><c:calendar-data>%s</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, data)
+	}
+	out.WriteString(`</d:multistatus>`)
+	_, _ = io.WriteString(w, out.String())
+}
+
+// newTestTarget returns a configuration with a single target whose task list
+// /dav/tasks/ is served by handler, and a client for that list.
+func newTestTarget(t *testing.T, handler http.HandlerFunc, rules ...config.Rule) (config.Config, config.TargetConfig, *caldav.Client) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL + "/dav/")
+	require.NoError(t, err)
+	targetURL, err := url.Parse(server.URL + "/dav/tasks/")
+	require.NoError(t, err)
+	target := config.TargetConfig{URL: targetURL, Server: config.DefaultServerName, Namespace: "/dav/tasks"}
+	cfg := config.Config{
+		Servers:  map[string]config.ServerConfig{config.DefaultServerName: {URL: serverURL}},
+		Targets:  map[string]config.TargetConfig{config.DefaultTargetName: target},
+		Sync:     config.SyncConfig{HorizonDays: 30, LookbackDays: 7},
+		Defaults: config.DefaultsConfig{Timezone: time.UTC},
+		Rules:    rules,
+	}
+
+	client, err := caldav.NewClient(server.URL+"/dav/", http.DefaultTransport)
+	require.NoError(t, err)
+	client, err = client.WithCalendar(targetURL.String())
+	require.NoError(t, err)
+
+	return cfg, target, client
+}
+
+func TestQueryExistingFallsBackToQueryWhenSyncFails(t *testing.T) {
+	due := time.Now().UTC().AddDate(0, 0, 1).Format("20060102T150405Z")
+	data := todoData("a", "SUMMARY:A", "DUE:"+due, "X-TASKSEED-ID:a")
+
+	var reports []string
+	cfg, target, client := newTestTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(raw), "sync-collection") {
+			reports = append(reports, "sync-collection")
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		reports = append(reports, "calendar-query")
+		writeMultistatus(w, map[string]string{"/dav/tasks/a.ics": data})
+	})
+	cache := &caldav.SyncCache{}
+
+	existing, err := queryExisting(t.Context(), cfg, target, client, ruleprocessor.New(cfg, target, client, false), cache)
+
+	require.NoError(t, err)
+	require.Len(t, existing, 1)
+	assert.Equal(t, "a", existing[0].UID)
+	assert.Equal(t, []string{"sync-collection", "calendar-query"}, reports)
+	assert.Empty(t, cache.Token)
+}
+
+func TestCompletionHistoryOutsideLookbackKeepsSchedule(t *testing.T) {
+	now := time.Now().UTC()
+	completed := todoData("old",
+		"SUMMARY:Descale the kettle",
+		"DUE:"+now.AddDate(0, 0, -40).Format("20060102")+"T090000Z",
+		"STATUS:COMPLETED",
+		"COMPLETED:"+now.AddDate(0, 0, -35).Format("20060102T150405Z"),
+		"X-TASKSEED-ID:old", "X-TASKSEED-RULE:kettle",
+		"X-TASKSEED-OCC:"+now.AddDate(0, 0, -40).Format("2006-01-02"),
+	)
+	unrelated := todoData("other", "SUMMARY:Other", "X-TASKSEED-ID:other", "X-TASKSEED-RULE:plants", "X-TASKSEED-OCC:2024-01-01")
+	rule := config.Rule{ID: "kettle", Title: "Descale the kettle", Schedule: config.RuleSchedule{Kind: config.ScheduleKindAfterCompletion, DaysAfterCompletion: 90}}
+
+	var puts int
+	cfg, target, client := newTestTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(raw), "time-range") {
+			writeMultistatus(w, nil)
+			return
+		}
+		writeMultistatus(w, map[string]string{"/dav/tasks/old.ics": completed, "/dav/tasks/other.ics": unrelated})
+	}, rule)
+	processor := ruleprocessor.New(cfg, target, client, false)
+
+	existing, err := queryExisting(t.Context(), cfg, target, client, processor, nil)
+	require.NoError(t, err)
+	require.Empty(t, existing)
+
+	existing, err = withCompletionHistory(t.Context(), client, cfg.Rules, existing)
+	require.NoError(t, err)
+	require.Len(t, existing, 1)
+	assert.Equal(t, "old", existing[0].UID)
+
+	processor.LoadExisting(existing)
+	processor.ProcessRule(t.Context(), rule)
+
+	assert.Zero(t, puts)
+}
+
+func TestMigrateRuleRetagsTasksOutsideSyncWindow(t *testing.T) {
+	objects := map[string]string{
+		"/dav/tasks/past.ics":   todoData("past", "SUMMARY:Past", "DUE:20200101T090000Z", "X-TASKSEED-ID:past", "X-TASKSEED-RULE:houseplants", "X-TASKSEED-OCC:2020-01-01"),
+		"/dav/tasks/future.ics": todoData("future", "SUMMARY:Future", "DUE:20400101T090000Z", "X-TASKSEED-ID:future", "X-TASKSEED-RULE:houseplants", "X-TASKSEED-OCC:2040-01-01"),
+		"/dav/tasks/other.ics":  todoData("other", "SUMMARY:Other", "X-TASKSEED-ID:other", "X-TASKSEED-RULE:kettle", "X-TASKSEED-OCC:2024-01-01"),
+	}
+
+	var puts []string
+	cfg, target, _ := newTestTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PROPFIND":
+			w.WriteHeader(http.StatusMultiStatus)
+		case "REPORT":
+			raw, _ := io.ReadAll(r.Body)
+			assert.NotContains(t, string(raw), "time-range")
+			assert.NotContains(t, string(raw), "sync-collection")
+			writeMultistatus(w, objects)
+		case http.MethodPut:
+			puts = append(puts, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s request", r.Method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}, config.Rule{ID: "water_plants"})
+	target.Create = true
+	cfg.Targets[config.DefaultTargetName] = target
+
+	require.NoError(t, MigrateRule(t.Context(), cfg, "houseplants", "water_plants", Options{}))
+
+	assert.ElementsMatch(t, []string{"/dav/tasks/past.ics", "/dav/tasks/future.ics"}, puts)
+}
@@ -2,15 +2,43 @@
 package schedule
 
 import (
+	"log/slog"
 	"slices"
 	"time"
 
+	"github.com/teambition/rrule-go"
+
 	"github.com/eikendev/taskseed/internal/config"
 	"github.com/eikendev/taskseed/internal/timeutil"
 )
 
+// shiftMargin bounds how far occurrences are moved when avoiding weekends and excluded dates.
+const shiftMargin = 14
+
+// Occurrence is a date produced by a rule's recurrence and the day it is due
+// on once weekend shifts and exclusions are applied. The nominal date keeps the
+// phase of the recurrence and identifies the occurrence.
+type Occurrence struct {
+	Nominal time.Time
+	Due     time.Time
+}
+
 // Occurrences returns all occurrence dates for a rule between startDate and endDate.
-func Occurrences(def config.RuleSchedule, startDate, endDate time.Time, tz *time.Location, anchor *time.Time) []time.Time {
+// Weekend occurrences are moved according to the rule's shift mode. Occurrences
+// on the rule's excluded dates or on holidays are then dropped or moved to a
+// working day according to the rule's exclusion policy.
+func Occurrences(def config.RuleSchedule, startDate, endDate time.Time, tz *time.Location, anchor *time.Time, holidays []config.Date) []time.Time {
+	var out []time.Time
+	for _, occ := range Expand(def, startDate, endDate, tz, anchor, holidays) {
+		out = append(out, occ.Due)
+	}
+	return out
+}
+
+// Expand returns the occurrences of a rule that are due between startDate and
+// endDate, ordered by due date, as Occurrences does, but keeps the nominal date
+// of each occurrence.
+func Expand(def config.RuleSchedule, startDate, endDate time.Time, tz *time.Location, anchor *time.Time, holidays []config.Date) []Occurrence {
 	if tz == nil {
 		tz = time.UTC
 	}
@@ -18,22 +46,206 @@ func Occurrences(def config.RuleSchedule, startDate, endDate time.Time, tz *time
 	start := timeutil.DateAt(startDate.In(tz))
 	end := timeutil.DateAt(endDate.In(tz))
 
+	excluded := excludedDays(def.ExceptDates, holidays)
+	if def.Kind == config.ScheduleKindAfterCompletion {
+		return completionOccurrence(def, start, end, anchor, excluded)
+	}
+
+	expandStart, expandEnd := start, end
+	if def.Shift != config.ShiftModeNone || (len(excluded) > 0 && def.OnExcluded != config.ExclusionPolicySkip) {
+		// Expand beyond the window so that occurrences moved into it are not lost.
+		// Without an anchor, the window start keeps fixing the phase as it would otherwise.
+		if anchor == nil {
+			anchor = &start
+		}
+		expandStart, expandEnd = start.AddDate(0, 0, -shiftMargin), end.AddDate(0, 0, shiftMargin)
+	}
+
+	dates := expand(def, expandStart, expandEnd, tz, anchor, excludedDays(nil, holidays))
+
+	return resolve(dates, def, excluded, start, end)
+}
+
+// DueDate returns the day a nominal occurrence is due on, or false when it is
+// dropped by the rule's exclusions.
+func DueDate(def config.RuleSchedule, nominal time.Time, holidays []config.Date) (time.Time, bool) {
+	return dueDate(nominal, def, excludedDays(def.ExceptDates, holidays))
+}
+
+func expand(def config.RuleSchedule, start, end time.Time, tz *time.Location, anchor *time.Time, holidays map[int]struct{}) []time.Time {
+	var out []time.Time
 	switch def.Kind {
 	case config.ScheduleKindWeekly:
-		return weekly(def.Weekdays, start, end)
+		out = weekly(def.Weekdays, start, end)
 	case config.ScheduleKindEveryNDays:
 		return everyNDays(def.EveryNDays, start, end, anchor)
 	case config.ScheduleKindMonthlyDay:
-		return monthlyDay(def.MonthDays, start, end)
+		out = monthlyDay(def.MonthDays, def.ClampToMonthEnd, start, end)
 	case config.ScheduleKindMonthlyNthWeekday:
-		return monthlyNthWeekday(def.Nth, def.NthWeekday, start, end)
+		out = monthlyNthWeekday(def.Nth, def.NthWeekday, start, end)
 	case config.ScheduleKindYearlyDate:
-		return yearlyDate(def.Month, def.Day, start, end)
+		out = yearlyDate(def.Month, def.Day, def.ClampToMonthEnd, start, end)
 	case config.ScheduleKindYearlyNthWeekday:
-		return yearlyNthWeekday(def.Month, def.Nth, def.YearlyNthWeekday, start, end)
+		out = yearlyNthWeekday(def.Month, def.Nth, def.YearlyNthWeekday, start, end)
+	case config.ScheduleKindRrule:
+		return rruleDates(def.RRule, start, end, anchor)
+	case config.ScheduleKindBusinessDays:
+		out = businessDays(def.Nth, holidays, start, end)
 	default:
 		return nil
 	}
+
+	return everyNthPeriod(out, def.Interval, periodOf(def.Kind), intervalAnchor(def.IntervalAnchor, tz))
+}
+
+// completionOccurrence returns the single occurrence of a completion-relative
+// rule. It is clamped to the window start itself rather than to a widened
+// expansion, and a shift or exclusion moving it before the start keeps it, as
+// the task is due either way.
+func completionOccurrence(def config.RuleSchedule, start, end time.Time, anchor *time.Time, excluded map[int]struct{}) []Occurrence {
+	dates := afterCompletion(def.DaysAfterCompletion, start, end, anchor)
+	return resolve(dates, def, excluded, start.AddDate(0, 0, -shiftMargin), end)
+}
+
+// resolve moves nominal dates off weekends and excluded dates and keeps those
+// due within start and end. Of several dates moved onto the same day, the first is kept.
+func resolve(dates []time.Time, def config.RuleSchedule, excluded map[int]struct{}, start, end time.Time) []Occurrence {
+	var out []Occurrence
+	for _, nominal := range dates {
+		due, ok := dueDate(nominal, def, excluded)
+		if !ok || due.Before(start) || due.After(end) {
+			continue
+		}
+		if slices.ContainsFunc(out, func(occ Occurrence) bool { return occ.Due.Equal(due) }) {
+			continue
+		}
+		out = append(out, Occurrence{Nominal: nominal, Due: due})
+	}
+
+	slices.SortStableFunc(out, func(a, b Occurrence) int { return a.Due.Compare(b.Due) })
+	return out
+}
+
+// dueDate applies the weekend shift and then the exclusion policy to a nominal date.
+func dueDate(nominal time.Time, def config.RuleSchedule, excluded map[int]struct{}) (time.Time, bool) {
+	date := shiftWeekend(nominal, def.Shift)
+	if _, ok := excluded[dayIndex(date)]; ok {
+		return shiftToWorkingDay(date, def.OnExcluded, excluded)
+	}
+	return date, true
+}
+
+// shiftWeekend moves a date on Saturday or Sunday to the adjacent weekday.
+func shiftWeekend(date time.Time, mode config.ShiftMode) time.Time {
+	switch {
+	case date.Weekday() == time.Saturday && mode == config.ShiftModeNextWeekday:
+		return date.AddDate(0, 0, 2)
+	case date.Weekday() == time.Sunday && mode == config.ShiftModeNextWeekday:
+		return date.AddDate(0, 0, 1)
+	case date.Weekday() == time.Saturday && mode == config.ShiftModePreviousWeekday:
+		return date.AddDate(0, 0, -1)
+	case date.Weekday() == time.Sunday && mode == config.ShiftModePreviousWeekday:
+		return date.AddDate(0, 0, -2)
+	default:
+		return date
+	}
+}
+
+func excludedDays(exceptDates, holidays []config.Date) map[int]struct{} {
+	out := make(map[int]struct{}, len(exceptDates)+len(holidays))
+	for _, d := range slices.Concat(exceptDates, holidays) {
+		out[dayIndex(d.In(time.UTC))] = struct{}{}
+	}
+	return out
+}
+
+func shiftToWorkingDay(date time.Time, policy config.ExclusionPolicy, excluded map[int]struct{}) (time.Time, bool) {
+	step := 0
+	switch policy {
+	case config.ExclusionPolicyNextWorkingDay:
+		step = 1
+	case config.ExclusionPolicyPreviousWorkingDay:
+		step = -1
+	default:
+		return time.Time{}, false
+	}
+
+	for range shiftMargin {
+		date = date.AddDate(0, 0, step)
+		if isWorkingDay(date, excluded) {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func isWorkingDay(date time.Time, excluded map[int]struct{}) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	_, ok := excluded[dayIndex(date)]
+	return !ok
+}
+
+// periodOf returns the function numbering the periods a kind repeats in.
+func periodOf(kind config.ScheduleKind) func(time.Time) int {
+	switch kind {
+	case config.ScheduleKindWeekly:
+		return weekIndex
+	case config.ScheduleKindYearlyDate, config.ScheduleKindYearlyNthWeekday:
+		return yearIndex
+	default:
+		return monthIndex
+	}
+}
+
+// intervalAnchor returns the date that fixes the phase of interval schedules,
+// falling back to the Unix epoch so the phase never depends on the current date.
+func intervalAnchor(anchor *config.Date, tz *time.Location) time.Time {
+	if anchor == nil {
+		return time.Date(1970, time.January, 1, 0, 0, 0, 0, tz)
+	}
+	return anchor.In(tz)
+}
+
+// everyNthPeriod keeps dates whose period is a multiple of interval periods away from the anchor.
+func everyNthPeriod(dates []time.Time, interval int, period func(time.Time) int, anchor time.Time) []time.Time {
+	if interval <= 1 {
+		return dates
+	}
+
+	var out []time.Time
+	for _, date := range dates {
+		distance := period(date) - period(anchor)
+		if ((distance%interval)+interval)%interval == 0 {
+			out = append(out, date)
+		}
+	}
+
+	return out
+}
+
+// weekIndex numbers weeks starting on Monday, counted from the Unix epoch.
+func weekIndex(t time.Time) int {
+	days := dayIndex(t) + 3 // 1970-01-01 was a Thursday.
+	if days < 0 {
+		return (days - 6) / 7
+	}
+	return days / 7
+}
+
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+func yearIndex(t time.Time) int {
+	return t.Year()
+}
+
+func dayIndex(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
 }
 
 func weekly(weekdays []time.Weekday, start, end time.Time) []time.Time {
@@ -85,28 +297,48 @@ func everyNDays(interval int, start, end time.Time, anchor *time.Time) []time.Ti
 	return out
 }
 
-func monthlyDay(days []int, start, end time.Time) []time.Time {
-	slices.Sort(days)
+// afterCompletion returns a single occurrence days after the anchor, or the window
+// start when that date has already passed or nothing was completed yet.
+func afterCompletion(days int, start, end time.Time, anchor *time.Time) []time.Time {
+	if days <= 0 {
+		return nil
+	}
+
+	next := start
+	if anchor != nil {
+		next = timeutil.DateAt(anchor.In(start.Location())).AddDate(0, 0, days)
+		if next.Before(start) {
+			next = start
+		}
+	}
+	if next.After(end) {
+		return nil
+	}
+
+	return []time.Time{next}
+}
+
+func monthlyDay(days []int, clamp bool, start, end time.Time) []time.Time {
 	var out []time.Time
 
-	for t := start; !t.After(end); t = t.AddDate(0, 1, 0) {
+	for t := firstOfMonth(start); !t.After(end); t = t.AddDate(0, 1, 0) {
 		y, m, _ := t.Date()
 
+		var dates []time.Time
 		for _, d := range days {
-			if d < 1 || d > 31 {
-				continue
-			}
-
-			date := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
-			if date.Month() != m {
+			date, ok := monthDate(y, m, d, clamp, t.Location())
+			if !ok || slices.ContainsFunc(dates, date.Equal) {
 				continue
 			}
 			if date.Before(start) || date.After(end) {
 				continue
 			}
 
-			out = append(out, date)
+			dates = append(dates, date)
 		}
+
+		slices.SortFunc(dates, time.Time.Compare)
+		out = append(out, dates...)
 	}
 
 	return out
@@ -115,14 +347,11 @@ func monthlyDay(days []int, start, end time.Time) []time.Time {
 func monthlyNthWeekday(n int, weekday time.Weekday, start, end time.Time) []time.Time {
 	var out []time.Time
 
-	for t := start; !t.After(end); t = t.AddDate(0, 1, 0) {
+	for t := firstOfMonth(start); !t.After(end); t = t.AddDate(0, 1, 0) {
 		y, m, _ := t.Date()
-		firstOfMonth := time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
-		offset := (int(weekday) - int(firstOfMonth.Weekday()) + 7) % 7
-		day := 1 + offset + (n-1)*7
 
-		date := time.Date(y, m, day, 0, 0, 0, 0, t.Location())
-		if date.Month() != m {
+		date, ok := nthWeekdayOfMonth(y, m, n, weekday, t.Location())
+		if !ok {
 			continue
 		}
 		if date.Before(start) || date.After(end) {
@@ -135,12 +364,12 @@ func monthlyNthWeekday(n int, weekday time.Weekday, start, end time.Time) []time
 	return out
 }
 
-func yearlyDate(month int, day int, start, end time.Time) []time.Time {
+func yearlyDate(month int, day int, clamp bool, start, end time.Time) []time.Time {
 	var out []time.Time
 
 	for y := start.Year(); y <= end.Year(); y++ {
-		date := time.Date(y, time.Month(month), day, 0, 0, 0, 0, start.Location())
-		if date.Month() != time.Month(month) || date.Before(start) || date.After(end) {
+		date, ok := monthDate(y, time.Month(month), day, clamp, start.Location())
+		if !ok || date.Before(start) || date.After(end) {
 			continue
 		}
 
@@ -154,12 +383,40 @@ func yearlyNthWeekday(month int, n int, weekday time.Weekday, start, end time.Ti
 	var out []time.Time
 
 	for y := start.Year(); y <= end.Year(); y++ {
-		firstOfMonth := time.Date(y, time.Month(month), 1, 0, 0, 0, 0, start.Location())
-		offset := (int(weekday) - int(firstOfMonth.Weekday()) + 7) % 7
-		day := 1 + offset + (n-1)*7
-		date := time.Date(y, time.Month(month), day, 0, 0, 0, 0, start.Location())
+		date, ok := nthWeekdayOfMonth(y, time.Month(month), n, weekday, start.Location())
+		if !ok || date.Before(start) || date.After(end) {
+			continue
+		}
+
+		out = append(out, date)
+	}
 
-		if date.Month() != time.Month(month) || date.Before(start) || date.After(end) {
+	return out
+}
+
+// businessDays returns the nth weekday of each month that is not a holiday,
+// where negative n counts back from the end of the month.
+func businessDays(n int, holidays map[int]struct{}, start, end time.Time) []time.Time {
+	var out []time.Time
+
+	for t := firstOfMonth(start); !t.After(end); t = t.AddDate(0, 1, 0) {
+		var days []time.Time
+		for d := t; d.Month() == t.Month(); d = d.AddDate(0, 0, 1) {
+			if isWorkingDay(d, holidays) {
+				days = append(days, d)
+			}
+		}
+
+		idx := n - 1
+		if n < 0 {
+			idx = len(days) + n
+		}
+		if n == 0 || idx < 0 || idx >= len(days) {
+			continue
+		}
+
+		date := days[idx]
+		if date.Before(start) || date.After(end) {
 			continue
 		}
 
@@ -168,3 +425,95 @@ func yearlyNthWeekday(month int, n int, weekday time.Weekday, start, end time.Ti
 
 	return out
 }
+
+// monthDate resolves a day of month, where negative days count back from the
+// last day of the month. With clamp, days beyond the month are moved to its edges.
+func monthDate(y int, m time.Month, day int, clamp bool, loc *time.Location) (time.Time, bool) {
+	if day == 0 || day < -31 || day > 31 {
+		return time.Time{}, false
+	}
+
+	last := daysInMonth(y, m)
+	if day < 0 {
+		day = last + day + 1
+	}
+
+	switch {
+	case day >= 1 && day <= last:
+	case !clamp:
+		return time.Time{}, false
+	case day < 1:
+		day = 1
+	default:
+		day = last
+	}
+
+	return time.Date(y, m, day, 0, 0, 0, 0, loc), true
+}
+
+// nthWeekdayOfMonth returns the nth weekday of a month, where negative n counts
+// back from the end of the month.
+func nthWeekdayOfMonth(y int, m time.Month, n int, weekday time.Weekday, loc *time.Location) (time.Time, bool) {
+	var day int
+
+	switch {
+	case n > 0:
+		first := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		day = 1 + offset + (n-1)*7
+	case n < 0:
+		lastDay := daysInMonth(y, m)
+		last := time.Date(y, m, lastDay, 0, 0, 0, 0, loc)
+		offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+		day = lastDay - offset + (n+1)*7
+	default:
+		return time.Time{}, false
+	}
+
+	if day < 1 || day > daysInMonth(y, m) {
+		return time.Time{}, false
+	}
+
+	return time.Date(y, m, day, 0, 0, 0, 0, loc), true
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+func daysInMonth(y int, m time.Month) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func rruleDates(rule string, start, end time.Time, anchor *time.Time) []time.Time {
+	option, err := rrule.StrToROptionInLocation(rule, start.Location())
+	if err != nil {
+		slog.Warn("failed to parse rrule", "rrule", rule, "error", err)
+		return nil
+	}
+
+	if option.Dtstart.IsZero() {
+		option.Dtstart = start
+		if anchor != nil {
+			option.Dtstart = timeutil.DateAt(anchor.In(start.Location()))
+		}
+	}
+
+	r, err := rrule.NewRRule(*option)
+	if err != nil {
+		slog.Warn("failed to build rrule", "rrule", rule, "error", err)
+		return nil
+	}
+
+	var out []time.Time
+	for _, t := range r.Between(start, end.AddDate(0, 0, 1), true) {
+		date := timeutil.DateAt(t.In(start.Location()))
+		if date.After(end) || slices.Contains(out, date) {
+			continue
+		}
+		out = append(out, date)
+	}
+
+	return out
+}
@@ -20,7 +20,7 @@ func TestOccurrencesWeeklyReturnsMatchingDates(t *testing.T) {
 	end := date(2023, time.January, 8)
 	expected := []time.Time{date(2023, time.January, 2), date(2023, time.January, 4)}
 
-	got := Occurrences(def, start, end, time.UTC, nil)
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
 
 	require.Len(t, got, len(expected))
 	assert.Equal(t, expected, got)
@@ -31,7 +31,7 @@ func TestOccurrencesUnknownKindReturnsNil(t *testing.T) {
 	start := date(2023, time.January, 1)
 	end := date(2023, time.January, 8)
 
-	got := Occurrences(def, start, end, time.UTC, nil)
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
 
 	assert.Nil(t, got)
 }
@@ -92,7 +92,7 @@ func TestMonthlyDayReturnsValidDates(t *testing.T) {
 		date(2023, time.March, 15),
 	}
 
-	got := monthlyDay(days, start, end)
+	got := monthlyDay(days, false, start, end)
 
 	require.Len(t, got, len(expected))
 	assert.Equal(t, expected, got)
@@ -103,11 +103,61 @@ func TestMonthlyDayIgnoresInvalidDays(t *testing.T) {
 	start := date(2023, time.January, 1)
 	end := date(2023, time.March, 31)
 
-	got := monthlyDay(days, start, end)
+	got := monthlyDay(days, false, start, end)
 
 	assert.Nil(t, got)
 }
 
+func TestMonthlyDayNegativeCountsFromMonthEnd(t *testing.T) {
+	days := []int{-1}
+	start := date(2024, time.January, 1)
+	end := date(2024, time.April, 30)
+	expected := []time.Time{
+		date(2024, time.January, 31),
+		date(2024, time.February, 29),
+		date(2024, time.March, 31),
+		date(2024, time.April, 30),
+	}
+
+	got := monthlyDay(days, false, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestMonthlyDaySkipsShortMonthsWithoutClamp(t *testing.T) {
+	days := []int{31}
+	start := date(2023, time.January, 31)
+	end := date(2023, time.April, 30)
+	expected := []time.Time{
+		date(2023, time.January, 31),
+		date(2023, time.March, 31),
+	}
+
+	got := monthlyDay(days, false, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestMonthlyDayClampsToMonthEnd(t *testing.T) {
+	days := []int{30, 31}
+	start := date(2023, time.January, 31)
+	end := date(2023, time.April, 30)
+	expected := []time.Time{
+		date(2023, time.January, 31),
+		date(2023, time.February, 28),
+		date(2023, time.March, 30),
+		date(2023, time.March, 31),
+		date(2023, time.April, 30),
+	}
+
+	got := monthlyDay(days, true, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
 func TestMonthlyNthWeekdayReturnsExpectedDates(t *testing.T) {
 	nth := 2
 	weekday := time.Monday
@@ -136,6 +186,34 @@ func TestMonthlyNthWeekdayOutOfRangeReturnsEmpty(t *testing.T) {
 	assert.Nil(t, got)
 }
 
+func TestMonthlyNthWeekdayNegativeReturnsLastWeekday(t *testing.T) {
+	nth := -1
+	weekday := time.Friday
+	start := date(2023, time.January, 1)
+	end := date(2023, time.March, 31)
+	expected := []time.Time{
+		date(2023, time.January, 27),
+		date(2023, time.February, 24),
+		date(2023, time.March, 31),
+	}
+
+	got := monthlyNthWeekday(nth, weekday, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestMonthlyNthWeekdayNegativeOutOfRangeReturnsEmpty(t *testing.T) {
+	nth := -6
+	weekday := time.Monday
+	start := date(2023, time.January, 1)
+	end := date(2023, time.March, 31)
+
+	got := monthlyNthWeekday(nth, weekday, start, end)
+
+	assert.Nil(t, got)
+}
+
 func TestYearlyDateReturnsExpectedDates(t *testing.T) {
 	month := 2
 	day := 10
@@ -147,7 +225,7 @@ func TestYearlyDateReturnsExpectedDates(t *testing.T) {
 		date(2024, time.February, 10),
 	}
 
-	got := yearlyDate(month, day, start, end)
+	got := yearlyDate(month, day, false, start, end)
 
 	require.Len(t, got, len(expected))
 	assert.Equal(t, expected, got)
@@ -159,11 +237,27 @@ func TestYearlyDateInvalidDayReturnsEmpty(t *testing.T) {
 	start := date(2022, time.January, 1)
 	end := date(2024, time.December, 31)
 
-	got := yearlyDate(month, day, start, end)
+	got := yearlyDate(month, day, false, start, end)
 
 	assert.Nil(t, got)
 }
 
+func TestYearlyDateClampsLeapDay(t *testing.T) {
+	month := 2
+	day := 29
+	start := date(2023, time.January, 1)
+	end := date(2024, time.December, 31)
+	expected := []time.Time{
+		date(2023, time.February, 28),
+		date(2024, time.February, 29),
+	}
+
+	got := yearlyDate(month, day, true, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
 func TestYearlyNthWeekdayReturnsExpectedDates(t *testing.T) {
 	month := 5
 	nth := 1
@@ -182,6 +276,23 @@ func TestYearlyNthWeekdayReturnsExpectedDates(t *testing.T) {
 	assert.Equal(t, expected, got)
 }
 
+func TestYearlyNthWeekdayNegativeReturnsLastWeekday(t *testing.T) {
+	month := 5
+	nth := -1
+	weekday := time.Monday
+	start := date(2023, time.January, 1)
+	end := date(2024, time.December, 31)
+	expected := []time.Time{
+		date(2023, time.May, 29),
+		date(2024, time.May, 27),
+	}
+
+	got := yearlyNthWeekday(month, nth, weekday, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
 func TestYearlyNthWeekdayOutOfRangeReturnsEmpty(t *testing.T) {
 	month := 5
 	nth := 6
@@ -193,3 +304,339 @@ func TestYearlyNthWeekdayOutOfRangeReturnsEmpty(t *testing.T) {
 
 	assert.Nil(t, got)
 }
+
+func TestOccurrencesRruleReturnsMatchingDates(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindRrule, RRule: "FREQ=MONTHLY;BYDAY=-1FR"}
+	start := date(2023, time.January, 1)
+	end := date(2023, time.March, 31)
+	expected := []time.Time{
+		date(2023, time.January, 27),
+		date(2023, time.February, 24),
+		date(2023, time.March, 31),
+	}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestRruleDatesKeepsPhaseFromAnchor(t *testing.T) {
+	rule := "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR"
+	anchor := date(2022, time.December, 30)
+	start := date(2023, time.January, 1)
+	end := date(2023, time.June, 30)
+	expected := []time.Time{
+		date(2023, time.February, 24),
+		date(2023, time.April, 28),
+		date(2023, time.June, 30),
+	}
+
+	got := rruleDates(rule, start, end, &anchor)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestRruleDatesHonorsExplicitDtstart(t *testing.T) {
+	rule := "DTSTART=20230103T000000Z;FREQ=WEEKLY;INTERVAL=2"
+	start := date(2023, time.January, 1)
+	end := date(2023, time.January, 31)
+	expected := []time.Time{
+		date(2023, time.January, 3),
+		date(2023, time.January, 17),
+		date(2023, time.January, 31),
+	}
+
+	got := rruleDates(rule, start, end, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestRruleDatesInvalidRuleReturnsNil(t *testing.T) {
+	start := date(2023, time.January, 1)
+	end := date(2023, time.January, 31)
+
+	got := rruleDates("FREQ=SOMETIMES", start, end, nil)
+
+	assert.Nil(t, got)
+}
+
+func TestOccurrencesWeeklyHonorsInterval(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:           config.ScheduleKindWeekly,
+		Weekdays:       []time.Weekday{time.Tuesday},
+		Interval:       2,
+		IntervalAnchor: &config.Date{Year: 2023, Month: time.January, Day: 10},
+	}
+	start := date(2023, time.January, 1)
+	end := date(2023, time.February, 5)
+	expected := []time.Time{date(2023, time.January, 10), date(2023, time.January, 24)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesMonthlyDayHonorsInterval(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:           config.ScheduleKindMonthlyDay,
+		MonthDays:      []int{15},
+		Interval:       3,
+		IntervalAnchor: &config.Date{Year: 2022, Month: time.November, Day: 1},
+	}
+	start := date(2023, time.January, 1)
+	end := date(2023, time.December, 31)
+	expected := []time.Time{
+		date(2023, time.February, 15),
+		date(2023, time.May, 15),
+		date(2023, time.August, 15),
+		date(2023, time.November, 15),
+	}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesYearlyDateHonorsInterval(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:           config.ScheduleKindYearlyDate,
+		Month:          3,
+		Day:            1,
+		Interval:       2,
+		IntervalAnchor: &config.Date{Year: 2021, Month: time.January, Day: 1},
+	}
+	start := date(2022, time.January, 1)
+	end := date(2026, time.December, 31)
+	expected := []time.Time{date(2023, time.March, 1), date(2025, time.March, 1)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesIntervalPhaseIsIndependentOfWindow(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindWeekly, Weekdays: []time.Weekday{time.Monday}, Interval: 2}
+
+	first := Occurrences(def, date(2023, time.January, 1), date(2023, time.January, 31), time.UTC, nil, nil)
+	second := Occurrences(def, date(2023, time.January, 10), date(2023, time.January, 31), time.UTC, nil, nil)
+
+	require.NotEmpty(t, second)
+	assert.Equal(t, first[len(first)-len(second):], second)
+}
+
+func TestWeekIndexStartsOnMonday(t *testing.T) {
+	sunday := date(2023, time.January, 8)
+	monday := date(2023, time.January, 9)
+
+	assert.Equal(t, weekIndex(sunday)+1, weekIndex(monday))
+	assert.Equal(t, -1, weekIndex(date(1969, time.December, 28)))
+	assert.Equal(t, 0, weekIndex(date(1969, time.December, 29)))
+}
+
+func TestOccurrencesSkipsExceptDates(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:        config.ScheduleKindEveryNDays,
+		EveryNDays:  1,
+		ExceptDates: []config.Date{{Year: 2023, Month: time.December, Day: 25}},
+	}
+	start := date(2023, time.December, 24)
+	end := date(2023, time.December, 26)
+	expected := []time.Time{date(2023, time.December, 24), date(2023, time.December, 26)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesSkipsHolidays(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindMonthlyDay, MonthDays: []int{1}}
+	holidays := []config.Date{{Year: 2024, Month: time.January, Day: 1}}
+	start := date(2024, time.January, 1)
+	end := date(2024, time.February, 29)
+	expected := []time.Time{date(2024, time.February, 1)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, holidays)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesMovesExcludedToNextWorkingDay(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:        config.ScheduleKindMonthlyDay,
+		MonthDays:   []int{25},
+		ExceptDates: []config.Date{{Year: 2026, Month: time.December, Day: 28}},
+		OnExcluded:  config.ExclusionPolicyNextWorkingDay,
+	}
+	holidays := []config.Date{{Year: 2026, Month: time.December, Day: 25}}
+	start := date(2026, time.December, 26)
+	end := date(2026, time.December, 31)
+	expected := []time.Time{date(2026, time.December, 29)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, holidays)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesMovesExcludedToPreviousWorkingDay(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:       config.ScheduleKindMonthlyDay,
+		MonthDays:  []int{25},
+		OnExcluded: config.ExclusionPolicyPreviousWorkingDay,
+	}
+	holidays := []config.Date{{Year: 2026, Month: time.December, Day: 25}}
+	start := date(2026, time.December, 1)
+	end := date(2026, time.December, 31)
+	expected := []time.Time{date(2026, time.December, 24)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, holidays)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestBusinessDaysReturnsNthBusinessDay(t *testing.T) {
+	start := date(2026, time.January, 1)
+	end := date(2026, time.February, 28)
+	expected := []time.Time{date(2026, time.January, 5), date(2026, time.February, 4)}
+
+	got := businessDays(3, nil, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestBusinessDaysNegativeSkipsHolidays(t *testing.T) {
+	holidays := map[int]struct{}{dayIndex(date(2026, time.December, 31)): {}}
+	start := date(2026, time.November, 1)
+	end := date(2026, time.December, 31)
+	expected := []time.Time{date(2026, time.November, 30), date(2026, time.December, 30)}
+
+	got := businessDays(-1, holidays, start, end)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesBusinessDaysUsesHolidays(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindBusinessDays, Nth: 1}
+	holidays := []config.Date{{Year: 2026, Month: time.January, Day: 1}}
+	start := date(2026, time.January, 1)
+	end := date(2026, time.January, 31)
+	expected := []time.Time{date(2026, time.January, 2)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, holidays)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesShiftsWeekendToNextWeekday(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindMonthlyDay, MonthDays: []int{1}, Shift: config.ShiftModeNextWeekday}
+	start := date(2026, time.February, 1)
+	end := date(2026, time.March, 31)
+	expected := []time.Time{date(2026, time.February, 2), date(2026, time.March, 2)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestOccurrencesShiftsWeekendIntoWindow(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindMonthlyDay, MonthDays: []int{1}, Shift: config.ShiftModePreviousWeekday}
+	start := date(2026, time.January, 30)
+	end := date(2026, time.January, 31)
+	expected := []time.Time{date(2026, time.January, 30)}
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	require.Len(t, got, len(expected))
+	assert.Equal(t, expected, got)
+}
+
+func TestAfterCompletionAddsDaysToAnchor(t *testing.T) {
+	completed := time.Date(2023, time.January, 3, 21, 15, 0, 0, time.UTC)
+	start := date(2023, time.January, 5)
+	end := date(2023, time.March, 31)
+	expected := []time.Time{date(2023, time.February, 2)}
+
+	got := afterCompletion(30, start, end, &completed)
+
+	assert.Equal(t, expected, got)
+}
+
+func TestAfterCompletionOverdueReturnsWindowStart(t *testing.T) {
+	completed := date(2022, time.November, 1)
+	start := date(2023, time.January, 5)
+	end := date(2023, time.March, 31)
+	expected := []time.Time{start}
+
+	got := afterCompletion(30, start, end, &completed)
+
+	assert.Equal(t, expected, got)
+}
+
+func TestAfterCompletionWithoutAnchorReturnsWindowStart(t *testing.T) {
+	start := date(2023, time.January, 5)
+	end := date(2023, time.March, 31)
+	expected := []time.Time{start}
+
+	got := afterCompletion(30, start, end, nil)
+
+	assert.Equal(t, expected, got)
+}
+
+func TestAfterCompletionBeyondWindowReturnsNil(t *testing.T) {
+	completed := date(2023, time.March, 15)
+	start := date(2023, time.March, 16)
+	end := date(2023, time.March, 31)
+
+	got := afterCompletion(30, start, end, &completed)
+
+	assert.Nil(t, got)
+}
+
+func TestOccurrencesAfterCompletionOverdueWithShiftReturnsWindowStart(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindAfterCompletion, DaysAfterCompletion: 7, Shift: config.ShiftModeNextWeekday}
+	completed := date(2024, time.May, 14)
+	start := date(2024, time.June, 3)
+	end := date(2024, time.July, 3)
+
+	got := Occurrences(def, start, end, time.UTC, &completed, nil)
+
+	assert.Equal(t, []time.Time{start}, got)
+}
+
+func TestOccurrencesAfterCompletionWithoutAnchorAndExclusionsReturnsWindowStart(t *testing.T) {
+	def := config.RuleSchedule{
+		Kind:                config.ScheduleKindAfterCompletion,
+		DaysAfterCompletion: 7,
+		ExceptDates:         []config.Date{{Year: 2024, Month: time.June, Day: 20}},
+		OnExcluded:          config.ExclusionPolicyNextWorkingDay,
+	}
+	start := date(2024, time.June, 3)
+	end := date(2024, time.July, 3)
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	assert.Equal(t, []time.Time{start}, got)
+}
+
+func TestOccurrencesAfterCompletionKeepsShiftBeforeWindowStart(t *testing.T) {
+	def := config.RuleSchedule{Kind: config.ScheduleKindAfterCompletion, DaysAfterCompletion: 7, Shift: config.ShiftModePreviousWeekday}
+	start := date(2024, time.June, 1) // Saturday
+	end := date(2024, time.July, 1)
+
+	got := Occurrences(def, start, end, time.UTC, nil, nil)
+
+	assert.Equal(t, []time.Time{date(2024, time.May, 31)}, got)
+}
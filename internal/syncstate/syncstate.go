@@ -0,0 +1,105 @@
+// Package syncstate persists incremental synchronization state between runs.
+package syncstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+)
+
+// version identifies the state file layout. Files of other versions are ignored.
+const version = 1
+
+// State holds the cached task lists, keyed by their collection URL.
+type State struct {
+	path        string
+	Version     int                          `json:"version"`
+	Collections map[string]*caldav.SyncCache `json:"collections"`
+}
+
+// Load reads the state file at path. A missing, unreadable, or outdated file
+// yields an empty state, which causes the next sync to fetch every object.
+func Load(path string) *State {
+	state := &State{path: path, Version: version, Collections: make(map[string]*caldav.SyncCache)}
+
+	raw, err := os.ReadFile(path) // #nosec G304 -- the state path is configured by the user
+	if errors.Is(err, fs.ErrNotExist) {
+		return state
+	}
+	if err != nil {
+		slog.Warn("ignoring unreadable sync state", "path", path, "error", err)
+		return state
+	}
+
+	var stored State
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		slog.Warn("ignoring corrupt sync state", "path", path, "error", err)
+		return state
+	}
+	if stored.Version != version {
+		slog.Info("ignoring sync state", "path", path, "version", stored.Version, "reason", "version_mismatch")
+		return state
+	}
+	for url, cache := range stored.Collections {
+		if cache != nil {
+			state.Collections[url] = cache
+		}
+	}
+
+	return state
+}
+
+// Collection returns the cache for the collection at url, creating an empty one if needed.
+func (s *State) Collection(url string) *caldav.SyncCache {
+	cache, ok := s.Collections[url]
+	if !ok {
+		cache = &caldav.SyncCache{}
+		s.Collections[url] = cache
+	}
+	return cache
+}
+
+// Save writes the state back to its file, replacing it atomically.
+func (s *State) Save() error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encode sync state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		slog.Error("failed to create sync state directory", "path", dir, "error", err)
+		return fmt.Errorf("create sync state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".taskseed-state-*")
+	if err != nil {
+		slog.Error("failed to create sync state file", "path", s.path, "error", err)
+		return fmt.Errorf("create sync state file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		slog.Error("failed to write sync state", "path", s.path, "error", err)
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		slog.Error("failed to write sync state", "path", s.path, "error", err)
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		slog.Error("failed to replace sync state", "path", s.path, "error", err)
+		return fmt.Errorf("replace sync state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package syncstate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/caldav"
+)
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "taskseed.json")
+	state := Load(path)
+	cache := state.Collection("https://cal.example.com/dav/tasks/")
+	cache.Token = "t1"
+	cache.Objects = map[string]caldav.CachedObject{"/dav/tasks/a.ics": {ETag: "1", Data: "BEGIN:VCALENDAR"}}
+
+	require.NoError(t, state.Save())
+
+	loaded := Load(path)
+	assert.Equal(t, cache, loaded.Collection("https://cal.example.com/dav/tasks/"))
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoadIgnoresOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taskseed.json")
+	raw := `{"version": 0, "collections": {"https://cal.example.com/dav/tasks/": {"token": "t1"}}}`
+	require.NoError(t, os.WriteFile(path, []byte(raw), 0o600))
+
+	state := Load(path)
+
+	assert.Empty(t, state.Collections)
+	assert.Equal(t, version, state.Version)
+}
+
+func TestLoadIgnoresCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taskseed.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	state := Load(path)
+
+	assert.Empty(t, state.Collections)
+	require.NoError(t, state.Save())
+	assert.Empty(t, Load(path).Collections)
+}
+
+func TestLoadMissingFileYieldsEmptyState(t *testing.T) {
+	state := Load(filepath.Join(t.TempDir(), "taskseed.json"))
+
+	assert.Empty(t, state.Collections)
+	assert.Equal(t, &caldav.SyncCache{}, state.Collection("https://cal.example.com/dav/tasks/"))
+}
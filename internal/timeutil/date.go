@@ -12,6 +12,12 @@ func DateAt(t time.Time) time.Time {
 	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
+// SameDateIn returns midnight in loc on the calendar date of t in its own location.
+func SameDateIn(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
 // FormatDate returns a formatted date or an empty string for nil.
 func FormatDate(date *time.Time) string {
 	if date == nil {
@@ -25,6 +25,15 @@ func TestDateAtKeepsDateWhenAlreadyMidnight(t *testing.T) {
 	assert.Equal(t, expected, got)
 }
 
+func TestSameDateInKeepsCalendarDate(t *testing.T) {
+	input := time.Date(2023, time.July, 2, 0, 0, 0, 0, time.FixedZone("east", 2*3600))
+	expected := time.Date(2023, time.July, 2, 0, 0, 0, 0, time.UTC)
+
+	got := SameDateIn(input, time.UTC)
+
+	assert.Equal(t, expected, got)
+}
+
 func TestFormatDateReturnsFormattedValue(t *testing.T) {
 	value := time.Date(2023, time.January, 5, 10, 0, 0, 0, time.UTC)
 	expected := "2023-01-05"
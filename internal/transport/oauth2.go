@@ -0,0 +1,256 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+// expiryMargin renews access tokens shortly before they expire.
+const expiryMargin = time.Minute
+
+// cachedToken is the on-disk form of an OAuth2 token.
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+func (t cachedToken) valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(expiryMargin).Before(t.Expiry))
+}
+
+// tokenResponse is the token endpoint's reply as defined by RFC 6749.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// oauth2Source hands out access tokens obtained with a refresh token and
+// keeps them in a cache file between runs.
+type oauth2Source struct {
+	mu           sync.Mutex
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	refreshToken string
+	cacheFile    string
+	token        cachedToken
+}
+
+// newOAuth2Source creates a token source that reaches the token endpoint
+// through base, so it shares the server's TLS settings and retries.
+func newOAuth2Source(name string, server config.ServerConfig, base http.RoundTripper) (*oauth2Source, error) {
+	cacheFile := server.Auth.CacheFile
+	if cacheFile == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("determine cache directory: %w", err)
+		}
+		cacheFile = filepath.Join(dir, "taskseed", "oauth2-"+name+".json")
+	}
+
+	source := &oauth2Source{
+		client:       &http.Client{Transport: base, Timeout: 30 * time.Second},
+		tokenURL:     server.Auth.TokenURL.String(),
+		clientID:     server.Auth.ClientID,
+		clientSecret: server.Auth.ClientSecret,
+		scopes:       server.Auth.Scopes,
+		refreshToken: server.Password,
+		cacheFile:    cacheFile,
+	}
+
+	cached, err := readTokenCache(cacheFile)
+	if err != nil {
+		slog.Warn("ignoring unreadable oauth2 token cache", "path", cacheFile, "error", err)
+	}
+	source.token = cached
+
+	return source, nil
+}
+
+// Token returns a valid access token, refreshing it when it is missing or
+// about to expire, or when force is set.
+func (s *oauth2Source) Token(ctx context.Context, force bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && s.token.valid(time.Now()) {
+		return s.token.AccessToken, nil
+	}
+
+	token, err := s.refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	if err := writeTokenCache(s.cacheFile, token); err != nil {
+		slog.Warn("failed to write oauth2 token cache", "path", s.cacheFile, "error", err)
+	}
+
+	return token.AccessToken, nil
+}
+
+// refresh exchanges the latest refresh token for a new access token. When a
+// refresh token rotated by the server is rejected, the configured one is tried.
+func (s *oauth2Source) refresh(ctx context.Context) (cachedToken, error) {
+	refreshToken := s.refreshToken
+	if s.token.RefreshToken != "" {
+		refreshToken = s.token.RefreshToken
+	}
+
+	token, err := s.exchange(ctx, refreshToken)
+	if err != nil && refreshToken != s.refreshToken {
+		slog.Debug("retrying oauth2 refresh with configured token", "error", err)
+		token, err = s.exchange(ctx, s.refreshToken)
+	}
+	return token, err
+}
+
+func (s *oauth2Source) exchange(ctx context.Context, refreshToken string) (cachedToken, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {s.clientID},
+	}
+	if s.clientSecret != "" {
+		form.Set("client_secret", s.clientSecret)
+	}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("request token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return cachedToken{}, fmt.Errorf("request token: %s", resp.Status)
+	}
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return cachedToken{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return cachedToken{}, errors.New("token response without access_token")
+	}
+
+	token := cachedToken{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if body.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+
+	slog.Debug("refreshed oauth2 access token", "expiry", token.Expiry)
+
+	return token, nil
+}
+
+func readTokenCache(path string) (cachedToken, error) {
+	raw, err := os.ReadFile(path) // #nosec G304 -- the cache path is configured by the user
+	if errors.Is(err, fs.ErrNotExist) {
+		return cachedToken{}, nil
+	}
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	var token cachedToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return cachedToken{}, err
+	}
+	return token, nil
+}
+
+func writeTokenCache(path string, token cachedToken) error {
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
+
+// oauth2Transport authenticates requests with OAuth2 access tokens and
+// refreshes the token once when the server rejects it.
+type oauth2Transport struct {
+	base   http.RoundTripper
+	source *oauth2Source
+}
+
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context(), false)
+	if err != nil {
+		return nil, fmt.Errorf("obtain oauth2 token: %w", err)
+	}
+
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	slog.Debug("refreshing rejected oauth2 access token")
+	token, err = t.source.Token(req.Context(), true)
+	if err != nil {
+		return nil, fmt.Errorf("refresh oauth2 token: %w", err)
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("rewind request body: %w", err)
+		}
+	}
+	return t.send(retry, token)
+}
+
+func (t *oauth2Transport) send(req *http.Request, token string) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
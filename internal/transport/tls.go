@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+// newHTTPTransport returns a copy of the default transport that verifies and
+// presents certificates as configured for the server.
+func newHTTPTransport(name string, cfg config.TLSConfig) (*http.Transport, error) {
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		slog.Error("failed to set up tls", "server", name, "error", err)
+		return nil, fmt.Errorf("set up tls for server %q: %w", name, err)
+	}
+	if cfg.InsecureSkipVerify {
+		slog.Warn("skipping tls certificate verification", "server", name)
+	}
+	httpTransport.TLSClientConfig = tlsConfig
+
+	return httpTransport, nil
+}
+
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, // #nosec G402 -- explicitly requested by the user
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// IsCertificateError reports whether err stems from verifying the server's
+// certificate or from a failed TLS handshake.
+func IsCertificateError(err error) bool {
+	var (
+		verifyErr    *tls.CertificateVerificationError
+		unknownErr   x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		echRejectErr *tls.ECHRejectionError
+	)
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &echRejectErr)
+}
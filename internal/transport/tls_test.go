@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+func TestNewTrustsConfiguredCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	untrusted, err := New("default", config.ServerConfig{})
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = untrusted.RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, IsCertificateError(err))
+
+	trusted, err := New("default", config.ServerConfig{TLS: config.TLSConfig{CAFile: caFile}})
+	require.NoError(t, err)
+	resp := send(t, trusted, server.URL, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestNewRejectsCAFileWithoutCertificates(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	_, err := New("default", config.ServerConfig{TLS: config.TLSConfig{CAFile: caFile}})
+
+	assert.Error(t, err)
+}
+
+func TestOAuth2TokenRequestsTrustConfiguredCA(t *testing.T) {
+	tokens := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(tokenResponse{AccessToken: "at-1", TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	t.Cleanup(tokens.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tokens.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	dav := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer at-1", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(dav.Close)
+
+	tokenURL, err := url.Parse(tokens.URL)
+	require.NoError(t, err)
+	rt, err := New("default", config.ServerConfig{
+		Password: "rt-1",
+		TLS:      config.TLSConfig{CAFile: caFile},
+		Auth: config.AuthConfig{
+			Type:      config.AuthTypeOauth2,
+			TokenURL:  tokenURL,
+			ClientID:  "taskseed",
+			CacheFile: filepath.Join(t.TempDir(), "token.json"),
+		},
+	})
+	require.NoError(t, err)
+
+	resp := send(t, rt, dav.URL, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
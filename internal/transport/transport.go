@@ -0,0 +1,61 @@
+// Package transport builds authenticated HTTP transports for CalDAV servers.
+package transport
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/justinrixx/retryhttp"
+
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+// New returns the HTTP transport for a server. Requests use the server's TLS
+// settings, are retried on transient failures, and carry its authentication.
+func New(name string, server config.ServerConfig) (http.RoundTripper, error) {
+	httpTransport, err := newHTTPTransport(name, server.TLS)
+	if err != nil {
+		return nil, err
+	}
+	base := retryhttp.New(retryhttp.WithTransport(httpTransport))
+
+	switch server.Auth.Type {
+	case config.AuthTypeBearer:
+		return &bearerTransport{base: base, token: server.Password}, nil
+	case config.AuthTypeOauth2:
+		source, err := newOAuth2Source(name, server, base)
+		if err != nil {
+			slog.Error("failed to set up oauth2", "server", name, "error", err)
+			return nil, fmt.Errorf("set up oauth2 for server %q: %w", name, err)
+		}
+		return &oauth2Transport{base: base, source: source}, nil
+	default:
+		return &basicTransport{base: base, username: server.Username, password: server.Password}, nil
+	}
+}
+
+// basicTransport authenticates requests with HTTP basic authentication.
+type basicTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *basicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
+
+// bearerTransport authenticates requests with a static bearer token.
+type bearerTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
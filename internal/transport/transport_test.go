@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eikendev/taskseed/internal/config"
+)
+
+func send(t *testing.T, rt http.RoundTripper, target, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPut, target, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func TestNewAppliesBasicAndBearerAuth(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+	}))
+	t.Cleanup(server.Close)
+
+	basic, err := New("default", config.ServerConfig{Username: "alice", Password: "s3cret"})
+	require.NoError(t, err)
+	send(t, basic, server.URL, "")
+	assert.Equal(t, "Basic YWxpY2U6czNjcmV0", header)
+
+	bearer, err := New("default", config.ServerConfig{Password: "tok", Auth: config.AuthConfig{Type: config.AuthTypeBearer}})
+	require.NoError(t, err)
+	send(t, bearer, server.URL, "")
+	assert.Equal(t, "Bearer tok", header)
+}
+
+func TestOAuth2RefreshesOnUnauthorizedAndCachesToken(t *testing.T) {
+	var issued atomic.Int32
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "taskseed", r.PostForm.Get("client_id"))
+		assert.Equal(t, "rt-1", r.PostForm.Get("refresh_token"))
+		n := issued.Add(1)
+		_ = json.NewEncoder(w).Encode(tokenResponse{AccessToken: fmt.Sprintf("at-%d", n), TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	t.Cleanup(tokens.Close)
+
+	var bodies []string
+	dav := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(raw))
+		if r.Header.Get("Authorization") != "Bearer at-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(dav.Close)
+
+	tokenURL, err := url.Parse(tokens.URL)
+	require.NoError(t, err)
+	cacheFile := filepath.Join(t.TempDir(), "token.json")
+	server := config.ServerConfig{
+		Password: "rt-1",
+		Auth:     config.AuthConfig{Type: config.AuthTypeOauth2, TokenURL: tokenURL, ClientID: "taskseed", CacheFile: cacheFile},
+	}
+
+	rt, err := New("default", server)
+	require.NoError(t, err)
+	resp := send(t, rt, dav.URL, "BEGIN:VCALENDAR")
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, int32(2), issued.Load())
+	assert.Equal(t, []string{"BEGIN:VCALENDAR", "BEGIN:VCALENDAR"}, bodies)
+
+	cached, err := readTokenCache(cacheFile)
+	require.NoError(t, err)
+	assert.Equal(t, "at-2", cached.AccessToken)
+	assert.Equal(t, "rt-1", cached.RefreshToken)
+	assert.True(t, cached.Expiry.After(time.Now()))
+
+	reloaded, err := New("default", server)
+	require.NoError(t, err)
+	send(t, reloaded, dav.URL, "")
+	assert.Equal(t, int32(2), issued.Load())
+}